@@ -0,0 +1,248 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+/*
+	staged calls extract with a staging directory in place of the
+	destination when the extraction is atomic, or its top level
+	directory is chosen once its entries are known. Once extract
+	succeeds, the staged files are moved into the destination, merging
+	with any directories already there, and the moves are undone if any
+	of them fail. The staging directory is removed whether or not the
+	extraction succeeds, including when the job is cancelled.
+
+	When the extraction is not atomic, the files of a deferred archive
+	are moved into place even if extract fails, as they would have been
+	written there directly.
+*/
+func (j *job) staged(destination string, extract func(destination string) error) (err error) {
+	if j.opts.DryRun {
+		return j.dryRun(destination, extract)
+	}
+	if !j.opts.Atomic && !j.pending {
+		return extract(destination)
+	}
+
+	err = os.MkdirAll(destination, 0755)
+	if err != nil {
+		return fmt.Errorf("%s: error creating destination: %v", destination, err)
+	}
+	stage, err := os.MkdirTemp(destination, ".extract-")
+	if err != nil {
+		return fmt.Errorf("error creating staging directory: %v", err)
+	}
+	defer os.RemoveAll(stage)
+	stage, err = filepath.Abs(stage)
+	if err != nil {
+		return err
+	}
+
+	tree := filepath.Join(stage, "tree")
+	m := &merge{root: destination, backup: filepath.Join(stage, "backup")}
+	for _, dir := range []string{tree, m.backup} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			return fmt.Errorf("error creating staging directory: %v", err)
+		}
+	}
+
+	j.stage, j.final = tree, destination
+	defer func() {
+		j.res.Destination = j.target(j.res.Destination)
+		j.stage, j.final = "", ""
+	}()
+
+	err = extract(tree)
+	if err == nil {
+		err = j.err()
+	}
+	if err != nil && (j.opts.Atomic || !j.pending) {
+		return err
+	}
+
+	final := destination
+	if j.pending {
+		var terr error
+		final, terr = j.topLevel(destination)
+		if terr != nil {
+			return terr
+		}
+		j.final, j.res.Destination = final, final
+		m.conflict = j.settle
+	}
+
+	merr := m.move(tree, final)
+	if merr != nil {
+		if rerr := m.rollback(); rerr != nil {
+			return fmt.Errorf("%v, and rolling back failed: %v", merr, rerr)
+		}
+		return merr
+	}
+	return err
+}
+
+/*
+	target returns the path that the staged path will be moved to once
+	an atomic extraction succeeds. Paths outside of the staging
+	directory, or of an extraction that is not atomic, are returned as
+	they are.
+*/
+func (j *job) target(path string) string {
+	if j.stage == "" {
+		return path
+	}
+	rel, ok := within(j.stage, path)
+	if !ok {
+		return path
+	}
+	return filepath.Join(j.final, rel)
+}
+
+/*
+	merge moves a staged tree into its destination, keeping what is
+	needed to undo each move.
+*/
+type merge struct {
+	root   string
+	backup string
+	moved  int
+	undo   []func() error
+
+	// conflict, when set, applies the overwrite policy to each file
+	// that replaces another.
+	conflict func(destination string, modTime time.Time) (string, bool, error)
+}
+
+/*
+	move moves the staged directory src to dst, or merges it into dst
+	when dst already exists.
+*/
+func (m *merge) move(src, dst string) error {
+	fi, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return m.rename(src, dst)
+	}
+	if err == nil && IsSymlink(fi) {
+		linked, ok, err := linkedDir(m.root, dst)
+		if err != nil {
+			return err
+		}
+		if ok {
+			dst = linked
+		}
+	}
+	return m.dir(src, dst)
+}
+
+/*
+	dir moves each entry of the staged directory src into dst. Entries
+	that replace a file are moved over it once the file is set aside in
+	the backup directory, and directories found in both are merged,
+	including those that dst holds as a symlink to a directory.
+*/
+func (m *merge) dir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("%s: error reading staged directory: %v", src, err)
+	}
+
+	for _, e := range entries {
+		s, d := filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())
+		fi, err := os.Lstat(d)
+		switch {
+		case os.IsNotExist(err):
+			err = m.rename(s, d)
+		case err != nil:
+			return fmt.Errorf("%s: %v", d, err)
+		case fi.IsDir() && e.IsDir():
+			err = m.dir(s, d)
+		case IsSymlink(fi) && e.IsDir():
+			err = m.link(s, d, e)
+		case fi.IsDir():
+			return fmt.Errorf("%s: cannot replace directory with a file", d)
+		default:
+			err = m.file(s, d, e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+	link merges the staged directory e, at s, into the directory that
+	the symlink d points to, or moves it over d when d does not point
+	to a directory.
+*/
+func (m *merge) link(s, d string, e os.DirEntry) error {
+	linked, ok, err := linkedDir(m.root, d)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return m.file(s, d, e)
+	}
+	return m.dir(s, linked)
+}
+
+/*
+	file moves the staged entry e, at s, over the file at d, once the
+	overwrite policy allows it.
+*/
+func (m *merge) file(s, d string, e os.DirEntry) error {
+	if m.conflict != nil {
+		info, err := e.Info()
+		if err != nil {
+			return fmt.Errorf("%s: %v", s, err)
+		}
+		to, ok, err := m.conflict(d, info.ModTime())
+		if !ok {
+			return err
+		}
+		if to != d {
+			return m.rename(s, to)
+		}
+	}
+	m.moved++
+	return m.replace(s, d, filepath.Join(m.backup, strconv.Itoa(m.moved)))
+}
+
+// rename moves s to d, recording how to move it back.
+func (m *merge) rename(s, d string) error {
+	if err := os.Rename(s, d); err != nil {
+		return fmt.Errorf("%s: error moving into place: %v", d, err)
+	}
+	m.undo = append(m.undo, func() error {
+		return os.Rename(d, s)
+	})
+	return nil
+}
+
+// replace moves s over d, once d has been set aside at backup.
+func (m *merge) replace(s, d, backup string) error {
+	if err := os.Rename(d, backup); err != nil {
+		return fmt.Errorf("%s: error setting aside existing file: %v", d, err)
+	}
+	m.undo = append(m.undo, func() error {
+		return os.Rename(backup, d)
+	})
+	return m.rename(s, d)
+}
+
+// rollback undoes the moves made so far, in reverse.
+func (m *merge) rollback() error {
+	var first error
+	for i := len(m.undo) - 1; i >= 0; i-- {
+		if err := m.undo[i](); err != nil && first == nil {
+			first = err
+		}
+	}
+	m.undo = nil
+	return first
+}
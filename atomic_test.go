@@ -0,0 +1,206 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func stagingLeft(t *testing.T, dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".extract-") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExtractAtomic(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_atomic")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	existing := filepath.Join(dest, "test", "80nj")
+	if err := WriteFile(existing, bytes.NewBufferString("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(dest, "test", "kept")
+	if err := WriteFile(kept, bytes.NewBufferString("kept"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"testdata/test.tar", "testdata/test.zip", "testdata/test.rar"} {
+		res, err := ExtractContext(context.Background(), []string{file}, Options{Destination: dest, Atomic: true})
+		if err != nil {
+			t.Fatalf("%s: expected no error but got %v", file, err)
+		}
+		if got := res.Archives[0].Destination; got != dest {
+			t.Errorf("%s: expected destination %s but got %s", file, dest, got)
+		}
+		if !FileExists(filepath.Join(dest, "test/0dmnf3/f2eeblv6")) {
+			t.Errorf("%s: expected test/0dmnf3/f2eeblv6 to be extracted", file)
+		}
+		if got, _ := ioutil.ReadFile(existing); string(got) == "old" {
+			t.Errorf("%s: expected existing file to be replaced", file)
+		}
+		if !FileExists(kept) {
+			t.Errorf("%s: expected existing directory to be merged", file)
+		}
+		if stagingLeft(t, dest) {
+			t.Errorf("%s: staging directory was left behind", file)
+		}
+	}
+}
+
+func TestExtractAtomicFailure(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_atomic")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	in := bytes.NewReader(bombArchive(t, 3, 1<<20))
+	_, err = ExtractStreamContext(context.Background(), in, dest, Options{Atomic: true, Limits: Limits{MaxEntries: 2}})
+	if !IsLimitExceededError(err) {
+		t.Fatalf("expected LimitExceededError but got %v", err)
+	}
+	entries, err := os.ReadDir(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected destination to be left empty but found %d entries", len(entries))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	in = bytes.NewReader(bombArchive(t, 3, 1<<20))
+	_, err = ExtractStreamContext(ctx, in, dest, Options{Atomic: true})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled but got %v", err)
+	}
+	if stagingLeft(t, dest) {
+		t.Errorf("staging directory was left behind")
+	}
+}
+
+func TestMergeRollback(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_merge")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	src, dst, backup := filepath.Join(parent, "src"), filepath.Join(parent, "dst"), filepath.Join(parent, "backup")
+	for name, content := range map[string]string{
+		"src/a":   "new",
+		"src/b/c": "new",
+		"src/d":   "file",
+		"dst/a":   "old",
+	} {
+		if err := WriteFile(filepath.Join(parent, name), bytes.NewBufferString(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A file cannot replace a directory, which fails the merge.
+	if err := Mkdir(filepath.Join(dst, "d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Mkdir(backup, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &merge{backup: backup}
+	if err := m.dir(src, dst); err == nil {
+		t.Fatalf("expected error but got nil")
+	}
+	if err := m.rollback(); err != nil {
+		t.Fatalf("expected no error rolling back but got %v", err)
+	}
+
+	if got, _ := ioutil.ReadFile(filepath.Join(dst, "a")); string(got) != "old" {
+		t.Errorf("expected dst/a to be restored but it holds %q", got)
+	}
+	if FileExists(filepath.Join(dst, "b")) {
+		t.Errorf("expected dst/b to be moved back")
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(src, "b", "c")); string(got) != "new" {
+		t.Errorf("expected src/b/c to be moved back but it holds %q", got)
+	}
+}
+
+func TestMergeSymlinkedDir(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_atomic")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+	outside, err := ioutil.TempDir("", "extract_outside")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(outside)
+
+	kept := filepath.Join(dest, "real", "kept")
+	if err := WriteFile(kept, bytes.NewBufferString("kept"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dest, "test")
+
+	for i, tc := range []struct {
+		file   string
+		atomic bool
+	}{
+		{file: "testdata/test.tar"},
+		{file: "testdata/test.zip", atomic: true},
+		{file: "testdata/test.rar"},
+	} {
+		os.Remove(link)
+		if err := os.Symlink("real", link); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ExtractContext(context.Background(), []string{tc.file}, Options{Destination: dest, Atomic: tc.atomic})
+		if err != nil {
+			t.Fatalf("[%d] %s: expected no error but got %v", i, tc.file, err)
+		}
+		if fi, err := os.Lstat(link); err != nil || !IsSymlink(fi) {
+			t.Errorf("[%d] %s: expected the symlink to be kept", i, tc.file)
+		}
+		if !FileExists(filepath.Join(dest, "real/0dmnf3/f2eeblv6")) {
+			t.Errorf("[%d] %s: expected the entries to be merged into the linked directory", i, tc.file)
+		}
+		if !FileExists(kept) {
+			t.Errorf("[%d] %s: expected the linked directory to keep its files", i, tc.file)
+		}
+		if stagingLeft(t, dest) {
+			t.Errorf("[%d] %s: staging directory was left behind", i, tc.file)
+		}
+	}
+
+	// A link that leaves the destination is not merged through.
+	os.Remove(link)
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ExtractContext(context.Background(), []string{"testdata/test.tar"}, Options{Destination: dest, Atomic: true})
+	if !IsIllegalPathError(err) {
+		t.Errorf("expected IllegalPathError but got %v", err)
+	}
+	if entries, _ := ioutil.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("expected nothing to be written outside of the destination")
+	}
+	if fi, err := os.Lstat(link); err != nil || !IsSymlink(fi) {
+		t.Errorf("expected the symlink to be kept")
+	}
+}
@@ -1,12 +1,161 @@
 package extract
 
 import (
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/vbauerster/mpb/v7"
 	"github.com/vbauerster/mpb/v7/decor"
 )
 
+/*
+	MPBProgress is a ProgressReporter that renders a bar for each archive
+	in an mpb.Progress container. Each bar tracks the compressed bytes
+	consumed against the size of the archive, along with the number of
+	entries extracted, the throughput and the estimated time remaining.
+	Archives of unknown size, such as streams, are shown with a spinner.
+*/
+type MPBProgress struct {
+	p *mpb.Progress
+
+	mu    sync.Mutex
+	bars  map[string]*archiveBar
+	total *mpb.Bar
+
+	// expected and done count the archives for the total bar, and are
+	// read atomically as the bar is rendered.
+	expected int64
+	done     int64
+}
+
+type archiveBar struct {
+	*mpb.Bar
+	entries int64
+}
+
+// NewMPBProgress returns a ProgressReporter that adds its bars to p.
+func NewMPBProgress(p *mpb.Progress) *MPBProgress {
+	return &MPBProgress{
+		p:    p,
+		bars: make(map[string]*archiveBar),
+	}
+}
+
+/*
+	AddTotalBar adds a bar tracking the combined progress of files, which
+	completes once each of them has been reported as done.
+*/
+func (m *MPBProgress) AddTotalBar(files []string) {
+	var size int64
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			size += fi.Size()
+		}
+	}
+
+	atomic.StoreInt64(&m.expected, int64(len(files)))
+	total := m.p.AddBar(size,
+		mpb.BarPriority(math.MaxInt32),
+		mpb.PrependDecorators(
+			decor.Name("Total:", decor.WC{W: 8, C: decor.DidentRight}),
+			decor.CountersKibiByte("% .1f / % .1f", decor.WCSyncSpaceR),
+		),
+		mpb.AppendDecorators(
+			decor.Any(func(decor.Statistics) string {
+				return fmt.Sprintf("%d/%d archives", atomic.LoadInt64(&m.done), atomic.LoadInt64(&m.expected))
+			}, decor.WCSyncSpace),
+			decor.AverageSpeed(decor.UnitKiB, "% .1f", decor.WCSyncSpace),
+			decor.OnComplete(decor.AverageETA(decor.ET_STYLE_GO, decor.WCSyncSpace), ""),
+		),
+	)
+	total.SetTotal(size, len(files) == 0)
+
+	m.mu.Lock()
+	m.total = total
+	m.mu.Unlock()
+}
+
+func (m *MPBProgress) ArchiveStart(archive string, size int64) {
+	ab := &archiveBar{}
+	if size <= 0 {
+		ab.Bar = AddNewBar(m.p, archive, time.Now())
+	} else {
+		ab.Bar = m.p.AddBar(size,
+			mpb.BarRemoveOnComplete(),
+			mpb.PrependDecorators(
+				decor.Name(archive+":", decor.WC{W: len(archive) + 2, C: decor.DidentRight}),
+				decor.OnComplete(decor.CountersKibiByte("% .1f / % .1f", decor.WCSyncSpaceR), "Done!"),
+			),
+			mpb.AppendDecorators(
+				decor.Any(func(decor.Statistics) string {
+					return fmt.Sprintf("%d entries", atomic.LoadInt64(&ab.entries))
+				}, decor.WCSyncSpace),
+				decor.AverageSpeed(decor.UnitKiB, "% .1f", decor.WCSyncSpace),
+				decor.OnComplete(decor.AverageETA(decor.ET_STYLE_GO, decor.WCSyncSpace), ""),
+			),
+		)
+		// Completion is left to ArchiveDone, as the bytes consumed may
+		// reach the size of the archive before the last entry is written.
+		ab.SetTotal(size, false)
+	}
+
+	m.mu.Lock()
+	m.bars[archive] = ab
+	m.mu.Unlock()
+}
+
+func (m *MPBProgress) EntryStart(archive, name string, size int64) {}
+func (m *MPBProgress) BytesWritten(archive string, n int64)        {}
+
+func (m *MPBProgress) BytesRead(archive string, n int64) {
+	m.mu.Lock()
+	ab, total := m.bars[archive], m.total
+	m.mu.Unlock()
+	if ab != nil {
+		ab.IncrInt64(n)
+	}
+	if total != nil {
+		total.IncrInt64(n)
+	}
+}
+
+func (m *MPBProgress) EntryDone(archive, name string, err error) {
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	ab := m.bars[archive]
+	m.mu.Unlock()
+	if ab != nil {
+		atomic.AddInt64(&ab.entries, 1)
+	}
+}
+
+func (m *MPBProgress) ArchiveDone(archive string, err error) {
+	m.mu.Lock()
+	ab, total := m.bars[archive], m.total
+	delete(m.bars, archive)
+	m.mu.Unlock()
+
+	done := atomic.AddInt64(&m.done, 1)
+	if total != nil && done >= atomic.LoadInt64(&m.expected) {
+		total.SetTotal(-1, true)
+	}
+
+	if ab == nil {
+		return
+	}
+	if err != nil {
+		ab.Abort(true)
+		return
+	}
+	ab.SetTotal(-1, true)
+}
+
 func AddNewBar(p *mpb.Progress, file string, start time.Time) (b *mpb.Bar) {
 	b = p.Add(
 		int64(1),
@@ -2,14 +2,9 @@ package extract
 
 import (
 	"fmt"
-	"io"
 	"os"
-	"sync/atomic"
-	"time"
 
-	"github.com/Galzzly/extract/v2/internal/magic"
 	"github.com/dsnet/compress/bzip2"
-	"github.com/vbauerster/mpb/v7"
 )
 
 type Bz2 struct {
@@ -22,57 +17,62 @@ type Bz2 struct {
 	the function will not return any error.
 */
 func (*Bz2) CheckFormat(filename string) error {
-	l := atomic.LoadUint32(&readLimit)
-
-	// Get the header of filename
-	h, err := GetFileHeader(filename, l)
+	ok, err := detectFile(filename, isBz2)
 	if err != nil {
 		return fmt.Errorf("problem looking at %s", filename)
 	}
-	mu.Lock()
-	defer mu.Unlock()
-
-	// Check if the file is a BZip2 file
-	var m = newMime("Bz2", magic.Bz2)
-	if !m.detector(h, l) {
+	if !ok {
 		return fmt.Errorf("%s is not a Bzip2 file", filename)
 	}
 	return nil
 }
 
+// isBz2 reports whether s is the start of a bzip2 file.
+func isBz2(s *Sniff) bool {
+	return s.Compression == "bz2"
+}
+
 /*
 	Extract will extract the file sent to the function
 */
-func (bz *Bz2) Extract(filename, destination string, p *mpb.Progress, start time.Time) (err error) {
-	b := AddNewBar(p, filename, start)
-	// Open the file in filename. We can assume if you've got this
-	// far that the file exists.
-	f, _ := os.Open(filename)
-	defer f.Close()
+func (bz *Bz2) Extract(filename, destination string, p ProgressReporter) (err error) {
+	return extractArchive(bz, filename, destination, p)
+}
 
-	// Open the destination file for writing
-	out, err := os.Create(destination)
+func (bz *Bz2) extract(j *job, filename, destination string) (err error) {
+	in, err := os.Open(filename)
 	if err != nil {
-		b.Abort(true)
-		return err
+		return fmt.Errorf("problem opening %s: %v", filename, err)
 	}
+	defer in.Close()
 
 	// Open the Reader
-	r, err := bzip2.NewReader(f, nil)
+	r, err := bzip2.NewReader(j.source(in), nil)
 	if err != nil {
-		b.Abort(true)
 		return err
 	}
 	defer r.Close()
 
-	// Write out the file
-	_, err = io.Copy(out, r)
+	// Write out the file, named after the bundle without its extension
+	name := GetFileName(filename)
+	f := File{FileInfo: streamInfo{name: name}, ReadCloser: r}
+	if !j.selected(name, f) {
+		return nil
+	}
+	name, ok := j.remap(name)
+	if !ok {
+		return nil
+	}
+	out, err := j.resolve(destination, name)
 	if err != nil {
-		b.Abort(true)
 		return err
 	}
-	b.SetTotal(1, true)
-	return
+	return j.writeFile(out, f)
+}
+
+func init() {
+	RegisterFormat("bz2", isBz2, func() Extractor { return NewBz2() }, 0)
+	RegisterExtensions("bz2", ".bz2")
 }
 
 func NewBz2() *Bz2 {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	extract "github.com/Galzzly/extract/v2"
+)
+
+var (
+	listJSON  = listCmd.Flag("json", "Print the entries as JSON.").Bool()
+	listFiles = listCmd.Arg("bundle", "Bundles to list.").Required().Strings()
+)
+
+/*
+list prints the entries of each bundle without extracting them, in
+the style of ls -l or as JSON.
+*/
+func list() error {
+
+	// Modes are given as in ls, rather than as numbers.
+	type entry struct {
+		extract.Entry
+		Mode string `json:"mode"`
+	}
+	type listing struct {
+		Archive string  `json:"archive"`
+		Entries []entry `json:"entries"`
+	}
+	var listings []listing
+	for _, f := range *listFiles {
+		entries, err := extract.List(f)
+		if err != nil {
+			return err
+		}
+		l := listing{Archive: f, Entries: make([]entry, len(entries))}
+		for i, e := range entries {
+			l.Entries[i] = entry{e, e.Mode.String()}
+		}
+		listings = append(listings, l)
+	}
+
+	if *listJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listings)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	for i, l := range listings {
+		if len(listings) > 1 {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s:\n", l.Archive)
+		}
+		for _, e := range l.Entries {
+			name := e.Name
+			if e.Link != "" {
+				name += " -> " + e.Link
+			}
+			crc := "-"
+			if e.CRC32 != 0 {
+				crc = fmt.Sprintf("%08x", e.CRC32)
+			}
+			fmt.Fprintf(w, "%s\t %s\t %s\t %s\t %s\t %s\t %s\n",
+				e.Entry.Mode, size(e.Size), size(e.CompressedSize), orDash(e.Method), crc,
+				e.ModTime.Local().Format("2006-01-02 15:04"), name)
+		}
+	}
+	return w.Flush()
+}
+
+// size formats a size for listing, with "-" for one that is not known.
+func size(n int64) string {
+	if n < 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
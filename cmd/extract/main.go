@@ -1,31 +1,100 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
 
 	extract "github.com/Galzzly/extract/v2"
+	"github.com/vbauerster/mpb/v7"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
+// The commands of the tool, whose flags are declared with them.
 var (
-	fileList = kingpin.Flag("file", "To decompress a single bundle. May be used more than once for multiple bundles.").Short('f').Strings()
-	destDir  = kingpin.Flag("dest", "Destination directory for the decompressed bundle.").Short('d').Default("./").String()
-	numC     = kingpin.Flag("count", "Number of concurrent extractions.").Short('c').Default("4").Uint32()
+	extractCmd = kingpin.Command("extract", "Extract bundles, the default when no command is named.").Default()
+	listCmd    = kingpin.Command("list", "List the contents of bundles without extracting them.")
+	testCmd    = kingpin.Command("test", "Check that bundles can be extracted in full, without writing anything.")
+	verifyCmd  = kingpin.Command("verify", "Check an extracted directory, or a bundle, against a manifest of checksums.")
 )
 
+var (
+	fileList  = extractCmd.Flag("file", "To decompress a single bundle. May be used more than once for multiple bundles. Use --file=- to read a bundle from stdin, at most once.").Short('f').Strings()
+	destDir   = extractCmd.Flag("dest", "Destination directory for the decompressed bundle.").Short('d').Default("./").String()
+	numC      = extractCmd.Flag("count", "Number of concurrent extractions.").Short('c').Default("4").Uint32()
+	atomic    = extractCmd.Flag("atomic", "Extract each bundle into a staging directory, and only move it into place once complete.").Bool()
+	sameOwner = extractCmd.Flag("same-owner", "Restore the owners, extended attributes and ACLs of entries. Owners are only restored when run as root.").Bool()
+	perms     = extractCmd.Flag("preserve-permissions", "Apply the permissions of entries as they are, including setuid, setgid and sticky bits, without the umask.").Short('p').Bool()
+	include   = extractCmd.Flag("include", "Only extract entries matching the glob pattern. May be used more than once.").PlaceHolder("PATTERN").Strings()
+	exclude   = extractCmd.Flag("exclude", "Do not extract entries matching the glob pattern. May be used more than once.").PlaceHolder("PATTERN").Strings()
+	strip     = extractCmd.Flag("strip-components", "Remove that many leading directories from the names of entries.").PlaceHolder("N").Int()
+	transform = extractCmd.Flag("transform", "Rename entries with a sed expression, such as 's/old/new/'. May be used more than once.").PlaceHolder("EXPR").Strings()
+	members   = extractCmd.Arg("member", "Names of the entries, or directories of entries, to extract from the bundles.").Strings()
+	overwrite = extractCmd.Flag("overwrite", "What to do with files that already exist: overwrite, skip, keep-newer, rename or fail.").Default("overwrite").Enum("overwrite", "skip", "keep-newer", "rename", "fail")
+	topLevel  = extractCmd.Flag("top-level", "When to extract a bundle into a directory named after it: auto, for those with several top level entries, always or never.").Default("auto").Enum("auto", "always", "never")
+	checksum  = extractCmd.Flag("checksum", "Hash to compute for each file as it is written: md5, sha1, sha224, sha256, sha384 or sha512. Defaults to sha256 when --manifest is set.").PlaceHolder("HASH").String()
+	manifest  = extractCmd.Flag("manifest", "Write a manifest of the checksums of the files extracted, relative to the destination directory.").PlaceHolder("FILE").String()
+	dryRun    = extractCmd.Flag("dry-run", "Print what would be created, overwritten, skipped or rejected, without writing anything.").Short('n').Bool()
+	strictFmt = extractCmd.Flag("strict-format", "Fail bundles whose extension names a different format than their content, rather than warning.").Bool()
+	manFormat = extractCmd.Flag("manifest-format", "Format of the manifest: sum, as written by sha256sum, or json.").Default("sum").Enum("sum", "json")
+)
+
+/*
+The extract command is run when no other is named, so that a bundle
+can be extracted with only its flags, as in "extract -f FILE". A member
+that shares the name of a command follows the flags, or the extract
+command itself.
+*/
 func main() {
-	if err := run(); err != nil {
+	kingpin.Version("2.0.0")
+	kingpin.CommandLine.HelpFlag.Short('h')
+	extractCmd.Validate(oneStdin)
+
+	var err error
+	switch kingpin.Parse() {
+	case listCmd.FullCommand():
+		err = list()
+	case testCmd.FullCommand():
+		err = test()
+	case verifyCmd.FullCommand():
+		err = verify()
+	default:
+		err = run()
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
 }
 
+/*
+oneStdin rejects --file=- given more than once, as stdin can only be
+read as a single bundle.
+*/
+func oneStdin(*kingpin.CmdClause) error {
+	var n int
+	for _, f := range *fileList {
+		if f == "-" {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("--file=- may only be given once, as stdin holds a single bundle")
+	}
+	return nil
+}
+
 func run() (err error) {
-	kingpin.Version("2.0.0")
-	kingpin.CommandLine.HelpFlag.Short('h')
-	kingpin.Parse()
+
+	/*
+		Interrupting the tool cancels the extractions in progress.
+	*/
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	/*
 		If no files are specified, attempt to get a list of files in the current directory.
@@ -43,20 +112,160 @@ func run() (err error) {
 		If it doesn't, attempt to create it.
 		Default is the current directory: ./
 	*/
-	if _, err := os.Stat(*destDir); err != nil {
+	if _, err := os.Stat(*destDir); err != nil && !*dryRun {
 		if err := os.MkdirAll(*destDir, 0755); err != nil {
 			return err
 		}
 	}
 
+	policy, err := extract.ParseOverwritePolicy(*overwrite)
+	if err != nil {
+		return err
+	}
+	top, err := extract.ParseTopLevelPolicy(*topLevel)
+	if err != nil {
+		return err
+	}
+	opts := extract.Options{
+		Destination:         *destDir,
+		Concurrency:         int(*numC),
+		Overwrite:           policy,
+		TopLevel:            top,
+		Atomic:              *atomic,
+		SameOwner:           *sameOwner,
+		PreservePermissions: *perms,
+		DryRun:              *dryRun,
+		StrictFormat:        *strictFmt,
+		Filter: extract.Filter{
+			Include: *include,
+			Exclude: *exclude,
+		},
+	}
+	if *checksum == "" && *manifest != "" {
+		*checksum = "sha256"
+	}
+	if *checksum != "" {
+		if opts.Checksum, err = extract.ParseHash(*checksum); err != nil {
+			return err
+		}
+	}
+
+	// Member names select the entries with exactly that name, rather
+	// than any element of the name, as in tar.
+	for _, m := range *members {
+		opts.Filter.Include = append(opts.Filter.Include, "/"+m)
+	}
+
+	/*
+		Leading directories are stripped before the transforms are
+		applied, each in the order given.
+	*/
+	opts.StripComponents = *strip
+	var renames []func(string) string
+	for _, expr := range *transform {
+		rename, err := extract.ParseTransform(expr)
+		if err != nil {
+			return err
+		}
+		renames = append(renames, rename)
+	}
+	if len(renames) > 0 {
+		opts.Rename = func(name string) string {
+			for _, rename := range renames {
+				name = rename(name)
+			}
+			return name
+		}
+	}
+
+	/*
+		A file of "-" is read from stdin, with the format detected from
+		the content of the stream.
+	*/
+	var files = make([]string, 0, len(*fileList))
+	var results []extract.ArchiveResult
+	for _, f := range *fileList {
+		if f != "-" {
+			files = append(files, f)
+			continue
+		}
+		r, err := extract.ExtractStreamContext(ctx, os.Stdin, *destDir, opts)
+		if err != nil {
+			return err
+		}
+		results = append(results, *r)
+	}
+	if *dryRun {
+		return printPlan(ctx, files, opts, results)
+	}
+	if len(files) == 0 {
+		return writeManifest(opts.Checksum, results)
+	}
+
 	/*
 		Extract the files
 	*/
-	if err := extract.Extract(fileList, *destDir, *numC); err != nil {
+	start := time.Now()
+	p := mpb.NewWithContext(ctx)
+	progress := extract.NewMPBProgress(p)
+	progress.AddTotalBar(files)
+	opts.Progress = progress
+	res, err := extract.ExtractContext(ctx, files, opts)
+	p.Wait()
+
+	for _, r := range res.Archives {
+		if r.Err != nil {
+			fmt.Println(r.Archive, "failed to extract in", r.Duration.Round(time.Millisecond))
+			continue
+		}
+		fmt.Println(r.Archive, "extracted to", r.Destination, "in", r.Duration.Round(time.Millisecond))
+		if len(r.Skipped) > 0 {
+			fmt.Println("  skipped", len(r.Skipped), "existing files")
+		}
+		for _, w := range r.Warnings {
+			fmt.Println("  warning:", w)
+		}
+	}
+	fmt.Println("\nExtraction complete in", time.Since(start))
+	if err != nil {
 		return err
 	}
+	return writeManifest(opts.Checksum, append(results, res.Archives...))
+}
+
+/*
+printPlan extracts the files as a dry run, printing the changes that
+each bundle would make after those of any streams already planned.
+*/
+func printPlan(ctx context.Context, files []string, opts extract.Options, results []extract.ArchiveResult) error {
+	var err error
+	if len(files) > 0 {
+		var res *extract.Result
+		res, err = extract.ExtractContext(ctx, files, opts)
+		results = append(results, res.Archives...)
+	}
 
-	return nil
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Println(r.Archive, "cannot be extracted:", r.Err)
+			continue
+		}
+		fmt.Println(r.Archive, "would be extracted to", r.Destination)
+		counts := make(map[extract.PlanAction]int)
+		for _, p := range r.Plan {
+			counts[p.Action]++
+			// Rejected paths are printed as they are named, as they may
+			// lead outside of the destination.
+			if p.Action == extract.PlanReject {
+				fmt.Printf("  %-9s %s: %s\n", p.Action, p.Path, p.Reason)
+				continue
+			}
+			fmt.Printf("  %-9s %s\n", p.Action, filepath.Join(r.Destination, p.Path))
+		}
+		fmt.Printf("  %d to create, %d to overwrite, %d skipped, %d rejected\n",
+			counts[extract.PlanCreate], counts[extract.PlanOverwrite], counts[extract.PlanSkip], counts[extract.PlanReject])
+	}
+	return err
 }
 
 func getFileList() (fileList *[]string, err error) {
@@ -66,6 +275,12 @@ func getFileList() (fileList *[]string, err error) {
 	}
 	var fl = make([]string, 0, len(files))
 	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if _, err := extract.GetFormat(f.Name()); err != nil {
+			continue
+		}
 		fl = append(fl, f.Name())
 	}
 	fileList = &fl
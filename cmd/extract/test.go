@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	extract "github.com/Galzzly/extract/v2"
+)
+
+var (
+	testFiles = testCmd.Arg("bundle", "Bundles to test.").Required().Strings()
+)
+
+/*
+test reads every entry of each bundle without writing anything,
+printing the entries that fail with their offsets. It returns an
+error when any of the bundles fail, so can gate a CI job.
+*/
+func test() error {
+
+	var failed int
+	for _, f := range *testFiles {
+		res, err := extract.Test(f)
+		if err != nil {
+			fmt.Println(f, "FAILED")
+			fmt.Println(" ", err)
+			failed++
+			continue
+		}
+		if len(res.Failures) == 0 {
+			fmt.Printf("%s OK: %d entries, %d bytes\n", f, res.Entries, res.Bytes)
+			continue
+		}
+		fmt.Println(f, "FAILED")
+		for _, e := range res.Failures {
+			fmt.Println(" ", e)
+		}
+		failed++
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d bundles failed the test", failed, len(*testFiles))
+	}
+	return nil
+}
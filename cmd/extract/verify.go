@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	extract "github.com/Galzzly/extract/v2"
+)
+
+var (
+	verifyManifest = verifyCmd.Flag("manifest", "Manifest to check against, in the sum or JSON format.").Short('m').PlaceHolder("FILE").Required().String()
+	verifyTarget   = verifyCmd.Arg("target", "Directory that the manifest is relative to, or a bundle to check without extracting.").Default(".").String()
+)
+
+/*
+verify checks an extracted tree, or a bundle, against a manifest
+written by --manifest or by sha256sum and similar tools. It returns an
+error when any file is missing or does not match.
+*/
+func verify() error {
+
+	f, err := os.Open(*verifyManifest)
+	if err != nil {
+		return err
+	}
+	m, err := extract.ReadManifest(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", *verifyManifest, err)
+	}
+
+	fi, err := os.Stat(*verifyTarget)
+	if err != nil {
+		return err
+	}
+	var failures []*extract.ChecksumError
+	if fi.IsDir() {
+		failures, err = m.Verify(*verifyTarget)
+	} else {
+		failures, err = m.VerifyArchive(*verifyTarget)
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range failures {
+		fmt.Println(e)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d files failed verification", len(failures), len(m.Files))
+	}
+	fmt.Printf("%s OK: %d files\n", *verifyTarget, len(m.Files))
+	return nil
+}
+
+/*
+writeManifest writes the checksums of the files extracted from each
+bundle to the file named by --manifest, as a single manifest relative
+to the destination directory.
+*/
+func writeManifest(h crypto.Hash, results []extract.ArchiveResult) error {
+	if *manifest == "" {
+		return nil
+	}
+	root, err := filepath.Abs(*destDir)
+	if err != nil {
+		return err
+	}
+
+	// Files written by more than one bundle are listed once, with the
+	// sum of the last written.
+	sums := make(map[string]string)
+	for _, r := range results {
+		if r.Manifest == nil {
+			continue
+		}
+		dest, err := filepath.Abs(r.Destination)
+		if err != nil {
+			return err
+		}
+		prefix, err := filepath.Rel(root, dest)
+		if err != nil {
+			return err
+		}
+		for _, c := range r.Manifest.Files {
+			sums[path.Join(filepath.ToSlash(prefix), c.Name)] = c.Sum
+		}
+	}
+	m := &extract.Manifest{Hash: h}
+	for name, sum := range sums {
+		m.Files = append(m.Files, extract.Checksum{Name: name, Sum: sum})
+	}
+	sort.Slice(m.Files, func(a, b int) bool {
+		return m.Files[a].Name < m.Files[b].Name
+	})
+
+	format := extract.SumManifest
+	if *manFormat == "json" {
+		format = extract.JSONManifest
+	}
+	f, err := os.Create(*manifest)
+	if err != nil {
+		return err
+	}
+	if err := m.Write(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,6 +1,9 @@
 package extract
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 /*
 	Setting the IllegalPathError when an illegal
@@ -21,6 +24,125 @@ func (e *IllegalPathError) Error() string {
 }
 
 func IsIllegalPathError(err error) bool {
-	_, ok := err.(*IllegalPathError)
-	return ok
+	var e *IllegalPathError
+	return errors.As(err, &e)
+}
+
+/*
+	IllegalLinkError is returned when a symlink or hardlink in an
+	archive has a target that is not allowed by the LinkPolicy of the
+	extraction.
+*/
+type IllegalLinkError struct {
+	Filename string
+	Link     string
+}
+
+func (e *IllegalLinkError) Error() string {
+	return fmt.Sprintf("Illegal link: %s -> %s", e.Filename, e.Link)
+}
+
+func IsIllegalLinkError(err error) bool {
+	var e *IllegalLinkError
+	return errors.As(err, &e)
+}
+
+/*
+	LimitExceededError is returned when the extraction of an archive
+	reaches one of its Limits. Limit holds the name of the field of
+	Limits that was reached, and Filename the entry being extracted.
+*/
+type LimitExceededError struct {
+	Limit    string
+	Filename string
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("Limit exceeded: %s: %s", e.Limit, e.Filename)
+}
+
+func IsLimitExceededError(err error) bool {
+	var e *LimitExceededError
+	return errors.As(err, &e)
+}
+
+/*
+	FormatMismatchError reports a file whose extension names the
+	format Expected while its content is detected as Detected.
+*/
+type FormatMismatchError struct {
+	Filename  string
+	Extension string
+	Expected  string
+	Detected  string
+}
+
+func (e *FormatMismatchError) Error() string {
+	return fmt.Sprintf("Format mismatch: %s: the extension %s is for %s, but the content is %s", e.Filename, e.Extension, e.Expected, e.Detected)
+}
+
+func IsFormatMismatchError(err error) bool {
+	var e *FormatMismatchError
+	return errors.As(err, &e)
+}
+
+/*
+	CorruptEntryError is reported by Test for an entry of an archive
+	that could not be read in full, or whose checksum did not match.
+	Offset is the position of the data of the entry within the archive,
+	or -1 when it is not known. Filename is empty when the archive
+	itself is damaged between entries.
+*/
+type CorruptEntryError struct {
+	Filename string
+	Offset   int64
+	Err      error
+}
+
+func (e *CorruptEntryError) Error() string {
+	name := e.Filename
+	if name == "" {
+		name = "archive"
+	}
+	if e.Offset < 0 {
+		return fmt.Sprintf("Corrupt entry: %s: %v", name, e.Err)
+	}
+	return fmt.Sprintf("Corrupt entry: %s at offset %d: %v", name, e.Offset, e.Err)
+}
+
+func (e *CorruptEntryError) Unwrap() error {
+	return e.Err
+}
+
+func IsCorruptEntryError(err error) bool {
+	var e *CorruptEntryError
+	return errors.As(err, &e)
+}
+
+/*
+	ChecksumError is reported when verifying a Manifest for a file whose
+	checksum does not match, with Actual holding its sum, or that could
+	not be read, with Err holding why.
+*/
+type ChecksumError struct {
+	Filename string
+	Expected string
+	Actual   string
+	Err      error
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Checksum failed: %s: %v", e.Filename, e.Err)
+	}
+	return fmt.Sprintf("Checksum mismatch: %s: expected %s but got %s", e.Filename, e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return e.Err
+}
+
+func IsChecksumError(err error) bool {
+	var e *ChecksumError
+	return errors.As(err, &e)
 }
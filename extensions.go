@@ -2,6 +2,11 @@ package extract
 
 import (
 	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // Format to validate file extension.
@@ -9,20 +14,154 @@ type Format interface {
 	CheckFormat(filename string) error
 }
 
-// formats lists the archive formats that the tool can work with
-var formats = []Format{
-	&Tar{},
-	&TarGz{},
-	&Gz{},
-	&Rar{},
-	&Zip{},
-	&Bz2{},
+/*
+	FormatDetector reports whether the file whose start is held in s is
+	of the format.
+*/
+type FormatDetector func(s *Sniff) bool
+
+// FormatFactory returns a new instance of a format, ready to extract.
+type FormatFactory func() Extractor
+
+type registeredFormat struct {
+	name     string
+	detect   FormatDetector
+	factory  FormatFactory
+	priority int
+	typ      reflect.Type
+}
+
+// registeredExt names the format of a file extension.
+type registeredExt struct {
+	name     string
+	fallback bool
+}
+
+/*
+	registry holds the formats that files are checked against, in the
+	order they are checked, and the formats named by file extensions.
+*/
+var registry struct {
+	sync.RWMutex
+	formats []*registeredFormat
+	exts    map[string]registeredExt
+}
+
+/*
+	RegisterFormat adds the format name to those that files are checked
+	against by ByFormat, replacing any format already registered with
+	that name. Formats are checked in order of priority, highest first,
+	and then in the order they were registered, so a format found within
+	another, such as TarGz within Gz, needs a higher priority than the
+	format it is found within. The built-in formats are registered with
+	a priority of 0, apart from TarGz, TarBz2 and TarXz at 10.
+
+	Formats registered from outside of this package are extracted by
+	ExtractContext using their Extract method, which is handed the
+	progress reporter of the extraction. The options that apply to the
+	entries of an archive are left to the format.
+*/
+func RegisterFormat(name string, detect FormatDetector, factory FormatFactory, priority int) {
+	f := &registeredFormat{
+		name:     name,
+		detect:   detect,
+		factory:  factory,
+		priority: priority,
+		typ:      reflect.TypeOf(factory()),
+	}
+
+	// The list is replaced rather than changed in place, as ByFormat
+	// reads it without holding the lock.
+	registry.Lock()
+	defer registry.Unlock()
+	formats := make([]*registeredFormat, 0, len(registry.formats)+1)
+	for _, r := range registry.formats {
+		if r.name != name {
+			formats = append(formats, r)
+		}
+	}
+	formats = append(formats, f)
+	sort.SliceStable(formats, func(a, b int) bool {
+		return formats[a].priority > formats[b].priority
+	})
+	registry.formats = formats
+}
+
+/*
+	RegisterExtensions records that files ending with any of exts, such
+	as ".tar.gz", are expected to be of the format name. A file whose
+	content is detected as another format is reported by ExtractContext
+	with a FormatMismatchError, as a warning or as the error of the
+	archive when Options.StrictFormat is set.
+*/
+func RegisterExtensions(name string, exts ...string) {
+	registerExts(name, false, exts)
+}
+
+/*
+	RegisterFallbackExtensions is RegisterExtensions for a format whose
+	content cannot always be detected, such as tar archives written
+	before the ustar magic was added. Files ending with any of exts that
+	no format detects are taken to be of the format.
+*/
+func RegisterFallbackExtensions(name string, exts ...string) {
+	registerExts(name, true, exts)
+}
+
+func registerExts(name string, fallback bool, exts []string) {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.exts == nil {
+		registry.exts = make(map[string]registeredExt)
+	}
+	for _, ext := range exts {
+		registry.exts[strings.ToLower(ext)] = registeredExt{name: name, fallback: fallback}
+	}
+}
+
+/*
+	extFormat returns the format named by the longest registered
+	extension that filename ends with, and that extension.
+*/
+func extFormat(filename string) (registeredExt, string, bool) {
+	lower := strings.ToLower(filepath.Base(filename))
+	registry.RLock()
+	defer registry.RUnlock()
+
+	var (
+		found registeredExt
+		ext   string
+	)
+	for e, r := range registry.exts {
+		if len(e) > len(ext) && strings.HasSuffix(lower, e) {
+			found, ext = r, e
+		}
+	}
+	return found, ext, ext != ""
+}
+
+// matches reports whether the format registered with name detects s.
+func matches(name string, s *Sniff) bool {
+	f, ok := registered(name)
+	return ok && f.detect(s)
+}
+
+// registered returns the format registered with name.
+func registered(name string) (*registeredFormat, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, f := range registry.formats {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return nil, false
 }
 
 /*
 	GetFormat returns the format of the file
 */
-func GetFormat(filename string) (interface{}, error) {
+func GetFormat(filename string) (Extractor, error) {
 	f, err := ByFormat(filename)
 	if err != nil {
 		return nil, err
@@ -33,31 +172,67 @@ func GetFormat(filename string) (interface{}, error) {
 
 /*
 	Will return an new instance of the file format based on the
-	magic numbers found in the file.
-*/
-func ByFormat(filename string) (interface{}, error) {
-	var ext interface{}
-	for _, c := range formats {
-		if err := c.CheckFormat(filename); err == nil {
-			ext = c
-			break
+	magic numbers found in the file, from the registered format
+	that first detects it. The start of the file is read once and
+	handed to each detector in turn. When no format detects it, a
+	file with a fallback extension is taken to be of that format.
+*/
+func ByFormat(filename string) (Extractor, error) {
+	x, _, err := detectFormat(filename)
+	return x, err
+}
+
+/*
+	detectFormat is ByFormat, also returning a FormatMismatchError when
+	the extension of filename names another format than its content.
+	A format found within the one the extension names, such as a tar.gz
+	named ".gz", is not a mismatch, as the content is also of the
+	format named.
+*/
+func detectFormat(filename string) (Extractor, *FormatMismatchError, error) {
+	s, err := SniffFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem looking at %s: %v", filename, err)
+	}
+
+	registry.RLock()
+	formats := registry.formats
+	registry.RUnlock()
+
+	byExt, ext, hasExt := extFormat(filename)
+	for _, f := range formats {
+		if !f.detect(s) {
+			continue
+		}
+		if hasExt && byExt.name != f.name && !matches(byExt.name, s) {
+			return f.factory(), &FormatMismatchError{Filename: filename, Extension: ext, Expected: byExt.name, Detected: f.name}, nil
 		}
+		return f.factory(), nil, nil
 	}
 
-	switch ext.(type) {
-	case *Tar:
-		return NewTar(), nil
-	case *TarGz:
-		return NewTarGz(), nil
-	case *Gz:
-		return NewGz(), nil
-	case *Rar:
-		return NewRar(), nil
-	case *Zip:
-		return NewZip(), nil
-	case *Bz2:
-		return NewBz2(), nil
+	if hasExt && byExt.fallback {
+		if f, ok := registered(byExt.name); ok {
+			return f.factory(), nil, nil
+		}
 	}
+	return nil, nil, fmt.Errorf("unable to recognise format by filename: %s", filename)
+}
 
-	return nil, fmt.Errorf("unable to recognise format by filename: %s", filename)
+/*
+	formatName returns the name that the format of f is registered
+	with, as reported in an ArchiveResult.
+*/
+func formatName(f interface{}) string {
+	if e, ok := f.(externalExtractor); ok {
+		f = e.Extractor
+	}
+	t := reflect.TypeOf(f)
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, r := range registry.formats {
+		if r.typ == t {
+			return r.name
+		}
+	}
+	return fmt.Sprintf("%T", f)
 }
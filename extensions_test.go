@@ -1,6 +1,21 @@
 package extract
 
-import "testing"
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/Galzzly/extract/v2/internal/magic"
+	"github.com/dsnet/compress/bzip2"
+	"github.com/ulikunitz/xz"
+)
 
 func TestFileFormat(t *testing.T) {
 	for i, tc := range []struct {
@@ -71,3 +86,377 @@ func TestFileFormat(t *testing.T) {
 		}
 	}
 }
+
+func TestByFormat(t *testing.T) {
+	for i, tc := range []struct {
+		file   string
+		expect string
+	}{
+		{file: "testdata/test.tar", expect: "tar"},
+		{file: "testdata/test.tar.gz", expect: "tar.gz"},
+		{file: "testdata/test.tgz", expect: "tar.gz"},
+		{file: "testdata/test.gz", expect: "gz"},
+		{file: "testdata/test.bz2", expect: "bz2"},
+		{file: "testdata/test-v7.tar.bz2", expect: "tar.bz2"},
+		{file: "testdata/test.rar", expect: "rar"},
+		{file: "testdata/test.zip", expect: "zip"},
+	} {
+		x, err := ByFormat(tc.file)
+		if err != nil {
+			t.Fatalf("[%d] %s: expected no error but got %v", i, tc.file, err)
+		}
+		if got := formatName(x); got != tc.expect {
+			t.Errorf("[%d] %s: expected %s but got %s", i, tc.file, tc.expect, got)
+		}
+	}
+	if _, err := ByFormat("testdata/test.txt"); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+// copyFormat is a format that copies the file as it is.
+type copyFormat struct{}
+
+func (copyFormat) Extract(filename, dest string, p ProgressReporter) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return WriteFile(filepath.Join(dest, "copied"), in, 0644)
+}
+
+/*
+	restoreRegistry puts the formats and extensions registered back as
+	they are once the test is done, so that the formats it registers do
+	not change the detection of the tests after it.
+*/
+func restoreRegistry(t *testing.T) {
+	registry.RLock()
+	formats := registry.formats
+	exts := make(map[string]registeredExt, len(registry.exts))
+	for e, r := range registry.exts {
+		exts[e] = r
+	}
+	registry.RUnlock()
+
+	t.Cleanup(func() {
+		registry.Lock()
+		defer registry.Unlock()
+		registry.formats, registry.exts = formats, exts
+	})
+}
+
+func TestRegisterFormat(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_format")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+	restoreRegistry(t)
+
+	// Ahead of gz, but only for gzip files holding a copy marker.
+	RegisterFormat("copy", func(s *Sniff) bool {
+		return s.Compression == "gz" && bytes.HasPrefix(s.Inner, []byte("copy:"))
+	}, func() Extractor { return copyFormat{} }, 20)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("copy: data"))
+	zw.Close()
+	data := buf.Bytes()
+	archive := filepath.Join(dest, "test.gz")
+	if err := ioutil.WriteFile(archive, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := ByFormat(archive)
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if _, ok := x.(copyFormat); !ok {
+		t.Errorf("expected copyFormat but got %T", x)
+	}
+	if x, _ := ByFormat("testdata/test.gz"); formatName(x) != "gz" {
+		t.Errorf("expected gz but got %s", formatName(x))
+	}
+
+	res, err := ExtractContext(context.Background(), []string{archive}, Options{Destination: dest})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if res.Archives[0].Format != "copy" {
+		t.Errorf("expected format copy but got %s", res.Archives[0].Format)
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(dest, "copied")); !bytes.Equal(got, data) {
+		t.Errorf("expected the archive to be extracted by its format")
+	}
+}
+
+func TestRegistryRestored(t *testing.T) {
+	t.Run("register", func(t *testing.T) {
+		restoreRegistry(t)
+		RegisterFormat("scratch", func(*Sniff) bool { return false }, func() Extractor { return copyFormat{} }, 0)
+		RegisterExtensions("scratch", ".scratch")
+	})
+	if _, ok := registered("scratch"); ok {
+		t.Errorf("expected the format to be removed once the test is done")
+	}
+	if _, _, ok := extFormat("a.scratch"); ok {
+		t.Errorf("expected the extension to be removed once the test is done")
+	}
+}
+
+func TestSniffFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract_sniff")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dir)
+
+	tarData, err := ioutil.ReadFile("testdata/test.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	xw, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xw.Write(tarData)
+	xw.Close()
+	txz := filepath.Join(dir, "test.tar.xz")
+	if err := ioutil.WriteFile(txz, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range []struct {
+		file        string
+		compression string
+		innerTar    bool
+	}{
+		{file: "testdata/test.tar"},
+		{file: "testdata/test.zip"},
+		{file: "testdata/test.tar.gz", compression: "gz", innerTar: true},
+		{file: "testdata/test.gz", compression: "gz"},
+		{file: "testdata/test.bz2", compression: "bz2"},
+		{file: txz, compression: "xz", innerTar: true},
+	} {
+		s, err := SniffFile(tc.file)
+		if err != nil {
+			t.Fatalf("[%d] %s: expected no error but got %v", i, tc.file, err)
+		}
+		if s.Compression != tc.compression {
+			t.Errorf("[%d] %s: expected compression %q but got %q", i, tc.file, tc.compression, s.Compression)
+		}
+		if got := magic.Tar(s.Inner, s.limit); got != tc.innerTar {
+			t.Errorf("[%d] %s: expected a tar within to be %v but got %v", i, tc.file, tc.innerTar, got)
+		}
+	}
+}
+
+// v7Tar returns a tar archive holding the file name without the ustar
+// magic, as written before it was added.
+func v7Tar(name string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 1, Format: tar.FormatUSTAR})
+	tw.Write([]byte("x"))
+	tw.Close()
+
+	b := buf.Bytes()
+	copy(b[257:265], make([]byte, 8))
+	var sum int64
+	for i, c := range b[:512] {
+		if i >= 148 && i < 156 {
+			c = ' '
+		}
+		sum += int64(c)
+	}
+	copy(b[148:156], fmt.Sprintf("%06o\x00 ", sum))
+	return b
+}
+
+func TestByFormatFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract_fallback")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dir)
+
+	// Content that no format detects.
+	data := bytes.Repeat([]byte("unknown "), 100)
+	for i, tc := range []struct {
+		file      string
+		shouldErr bool
+	}{
+		{file: "old.tar"},
+		{file: "OLD.TAR"},
+		{file: "old.bin", shouldErr: true},
+	} {
+		path := filepath.Join(dir, tc.file)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		x, err := ByFormat(path)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("[%d] %s: expected error but got %T", i, tc.file, x)
+			}
+			continue
+		}
+		if _, ok := x.(*Tar); err != nil || !ok {
+			t.Errorf("[%d] %s: expected *Tar but got %T (%v)", i, tc.file, x, err)
+		}
+	}
+}
+
+func TestTarChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract_v7")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dir)
+
+	data := v7Tar("old")
+	var gz, bz, xzb bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(data)
+	zw.Close()
+	xw, err := xz.NewWriter(&xzb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xw.Write(data)
+	xw.Close()
+	bw, err := bzip2.NewWriter(&bz, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bw.Write(data)
+	bw.Close()
+	broken := append([]byte(nil), data...)
+	broken[148]++
+
+	for i, tc := range []struct {
+		data   []byte
+		expect string
+	}{
+		{data: data, expect: "tar"},
+		{data: gz.Bytes(), expect: "tar.gz"},
+		{data: xzb.Bytes(), expect: "tar.xz"},
+		{data: broken},
+		{data: make([]byte, 1024)},
+	} {
+		// Named without an extension, so only the content is used.
+		path := filepath.Join(dir, strconv.Itoa(i))
+		if err := ioutil.WriteFile(path, tc.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		x, err := ByFormat(path)
+		if tc.expect == "" {
+			if err == nil {
+				t.Errorf("[%d] expected error but got %T", i, x)
+			}
+			continue
+		}
+		if err != nil || formatName(x) != tc.expect {
+			t.Fatalf("[%d] expected %s but got %T (%v)", i, tc.expect, x, err)
+		}
+		dest := filepath.Join(dir, "out"+strconv.Itoa(i))
+		if _, err := ExtractContext(context.Background(), []string{path}, Options{Destination: dest}); err != nil {
+			t.Fatalf("[%d] expected no error but got %v", i, err)
+		}
+		if !FileExists(filepath.Join(dest, "old")) {
+			t.Errorf("[%d] expected old to be extracted", i)
+		}
+	}
+
+	dest := filepath.Join(dir, "stream")
+	res, err := ExtractStreamContext(context.Background(), &bz, dest, Options{})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if res.Format != "tar.bz2" || !FileExists(filepath.Join(dest, "old")) {
+		t.Errorf("expected old to be extracted from a tar.bz2 stream but got %s", res.Format)
+	}
+}
+
+func TestTarBz2(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_tbz2")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	res, err := ExtractContext(context.Background(), []string{"testdata/test-v7.tar.bz2"}, Options{Destination: dest})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if res.Archives[0].Format != "tar.bz2" || len(res.Archives[0].Warnings) != 0 {
+		t.Errorf("expected tar.bz2 without warnings but got %s %v", res.Archives[0].Format, res.Archives[0].Warnings)
+	}
+	for _, name := range []string{"v7/hello.txt", "v7/world.txt"} {
+		if !FileExists(filepath.Join(dest, name)) {
+			t.Errorf("expected %s to be extracted", name)
+		}
+	}
+	if FileExists(filepath.Join(dest, "test-v7.tar")) {
+		t.Errorf("expected the archive not to be written out as a single file")
+	}
+}
+
+func TestFormatMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract_mismatch")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := ioutil.ReadFile("testdata/test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(dir, "test.tar.gz")
+	if err := ioutil.WriteFile(archive, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ExtractContext(context.Background(), []string{archive}, Options{Destination: filepath.Join(dir, "warn")})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if a := res.Archives[0]; a.Format != "zip" || len(a.Warnings) != 1 {
+		t.Errorf("expected a zip with a warning but got %s with %v", a.Format, a.Warnings)
+	}
+
+	_, err = ExtractContext(context.Background(), []string{archive}, Options{Destination: filepath.Join(dir, "strict"), StrictFormat: true})
+	if !IsFormatMismatchError(err) {
+		t.Errorf("expected FormatMismatchError but got %v", err)
+	}
+	if FileExists(filepath.Join(dir, "strict")) {
+		t.Errorf("expected nothing to be extracted")
+	}
+
+	// Matching extensions are not reported.
+	res, err = ExtractContext(context.Background(), []string{"testdata/test.tgz"}, Options{Destination: filepath.Join(dir, "match"), StrictFormat: true})
+	if err != nil || len(res.Archives[0].Warnings) != 0 {
+		t.Errorf("expected no error or warnings but got %v (%v)", res.Archives[0].Warnings, err)
+	}
+
+	// Nor are formats found within the one named by the extension.
+	for i, src := range []string{"testdata/test.tar.gz", "testdata/test-v7.tar.bz2"} {
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nested := filepath.Join(dir, "nested"+strconv.Itoa(i)+filepath.Ext(src))
+		if err := ioutil.WriteFile(nested, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, mismatch, err := detectFormat(nested)
+		if err != nil || mismatch != nil {
+			t.Errorf("[%d] %s: expected no mismatch but got %v (%v)", i, nested, mismatch, err)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package extract
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -16,7 +18,7 @@ import (
 var readLimit uint32 = 3072
 
 type Extractor interface {
-	Extract(filename, dest string, p *mpb.Progress, start time.Time) error
+	Extract(filename, dest string, p ProgressReporter) error
 }
 
 type File struct {
@@ -25,6 +27,23 @@ type File struct {
 	io.ReadCloser
 }
 
+/*
+	streamInfo describes the single file held in a compressed stream,
+	for which there is no header beyond what the compression format
+	records.
+*/
+type streamInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (si streamInfo) Name() string       { return si.name }
+func (si streamInfo) Size() int64        { return -1 }
+func (si streamInfo) Mode() os.FileMode  { return 0644 }
+func (si streamInfo) ModTime() time.Time { return si.modTime }
+func (si streamInfo) IsDir() bool        { return false }
+func (si streamInfo) Sys() interface{}   { return nil }
+
 /*
 	ReadFakeCloser is an io.Reader that has
 	a no-op close method to satisfy the
@@ -37,48 +56,118 @@ type ReadFakeCloser struct {
 // Close implements io.Closer.
 func (rfc ReadFakeCloser) Close() error { return nil }
 
-func Extract(fileList *[]string, destDir string, numC uint32) (err error) {
+/*
+	Extract extracts each of the files in fileList into destDir, with up
+	to numC archives extracted at once, showing a progress bar for each.
+	The Result holds the outcome of every archive, and an error joining
+	the errors of those that failed is returned.
+*/
+func Extract(fileList *[]string, destDir string, numC uint32) (*Result, error) {
 	start := time.Now()
 
-	var wg sync.WaitGroup
-	wg.Add(len(*fileList))
+	p := mpb.New()
+	progress := NewMPBProgress(p)
+	progress.AddTotalBar(*fileList)
+	res, err := ExtractContext(context.Background(), *fileList, Options{
+		Destination: destDir,
+		Concurrency: int(numC),
+		Progress:    progress,
+	})
+	p.Wait()
 
-	p := mpb.New(mpb.WithWaitGroup(&wg))
-	workers := make(chan int, numC)
-	for _, f := range *fileList {
-		file := f
-		go extractFile(file, destDir, &wg, p, workers)
+	for _, r := range res.Archives {
+		if r.Err != nil {
+			fmt.Println(r.Archive, "failed to extract in", r.Duration)
+			continue
+		}
+		fmt.Println(r.Archive, "extracted to", r.Destination, "in", r.Duration)
 	}
+	fmt.Println("\nExtraction complete in", time.Since(start))
+	return res, err
+}
 
-	p.Wait()
+/*
+	ExtractContext extracts each of the files into opts.Destination, with
+	up to opts.Concurrency archives extracted at once. Cancelling ctx stops
+	any extraction in progress, including a file part way through being
+	written, and returns promptly. The Result holds the outcome of each
+	archive, including those cut short or never started.
+*/
+func ExtractContext(ctx context.Context, files []string, opts Options) (*Result, error) {
+	n := opts.Concurrency
+	if n <= 0 {
+		n = DefaultConcurrency
+	}
+
+	res := &Result{Archives: make([]ArchiveResult, len(files))}
+	workers := make(chan struct{}, n)
+
+	var wg sync.WaitGroup
+	for i, f := range files {
+		res.Archives[i].Archive = f
+		res.Archives[i].Destination = opts.Destination
+
+		wg.Add(1)
+		go func(r *ArchiveResult) {
+			defer wg.Done()
+			select {
+			case workers <- struct{}{}:
+				defer func() { <-workers }()
+			case <-ctx.Done():
+				newJob(ctx, &opts, r).fail(ctx.Err())
+				return
+			}
+			extractContextFile(ctx, r, &opts)
+		}(&res.Archives[i])
+	}
 	wg.Wait()
-	close(workers)
 
-	fmt.Println("\nExtraction complete in", time.Since(start))
-	return
+	return res, res.Err()
 }
 
-func extractFile(file, destDir string, wg *sync.WaitGroup, p *mpb.Progress, worker chan int) (err error) {
-	defer wg.Done()
-	worker <- 1
-	start := time.Now()
-	iface, err := GetFormat(file)
+/*
+	extractContextFile detects the format of the archive in r and
+	extracts it, recording the outcome in r.
+*/
+func extractContextFile(ctx context.Context, r *ArchiveResult, opts *Options) {
+	j := newJob(ctx, opts, r)
+	if err := ctx.Err(); err != nil {
+		j.fail(err)
+		return
+	}
+	iface, mismatch, err := detectFormat(r.Archive)
 	if err != nil {
-		<-worker
-		return nil
+		j.fail(err)
+		return
+	}
+	if mismatch != nil {
+		if opts.StrictFormat {
+			j.fail(mismatch)
+			return
+		}
+		j.warn("%v", mismatch)
 	}
+	x, ok := iface.(archiveExtractor)
+	if !ok {
+		x = externalExtractor{iface}
+	}
+	j.run(x, r.Archive, opts.Destination)
+}
 
-	u, _ := iface.(Extractor)
+/*
+	externalExtractor extracts an archive of a format registered from
+	outside of this package, which knows nothing of the job, through
+	its Extract method.
+*/
+type externalExtractor struct {
+	Extractor
+}
 
-	err = u.Extract(file, destDir, p, start)
-	if err != nil {
-		<-worker
-		fmt.Println("\r", file, "failed to extract in", time.Since(start))
-		return err
+func (e externalExtractor) extract(j *job, filename, destination string) error {
+	if j.opts.DryRun {
+		return fmt.Errorf("a dry run is not supported for %s archives", formatName(e))
 	}
-	fmt.Println(file, "extracted to", destDir, "in", time.Since(start))
-	<-worker
-	return nil
+	return e.Extract(filename, destination, j.progress)
 }
 
 /*
@@ -109,15 +198,37 @@ func TopLevels(paths []string) bool {
 	return false
 }
 
+// archiveExts are the extensions removed by DirFromFile, longest first.
+var archiveExts = []string{
+	".tar.gz", ".tar.bz2", ".tar.xz", ".tar.zst",
+	".tgz", ".tbz2", ".tbz", ".txz", ".tzst",
+	".tar", ".zip", ".rar", ".gz", ".bz2", ".xz", ".zst",
+}
+
+// rarPart matches the volume number of a multi-volume rar archive.
+var rarPart = regexp.MustCompile(`(?i)\.part[0-9]+$`)
+
 /*
-	DirFromFile returns a directory based on the filename
-	provided to the function.
+	DirFromFile returns the name of the directory that the archive
+	filename is extracted into when it is wrapped in one. This is the
+	base name of the archive with its archive and compression extensions
+	removed, so "app-1.2.3.tar.gz" gives "app-1.2.3". Only the last
+	extension is removed from a name without a known one.
 */
 func DirFromFile(filename string) (dir string) {
 	dir = filepath.Base(filename)
-	fd := strings.Index(dir, ".")
-	if fd > -1 {
-		dir = dir[:fd]
+	lower := strings.ToLower(dir)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(lower, ext) && len(ext) < len(dir) {
+			dir = dir[:len(dir)-len(ext)]
+			if ext == ".rar" {
+				dir = rarPart.ReplaceAllString(dir, "")
+			}
+			return dir
+		}
+	}
+	if ext := filepath.Ext(dir); ext != dir {
+		dir = strings.TrimSuffix(dir, ext)
 	}
 	return dir
 }
@@ -131,19 +242,6 @@ func FileExists(filename string) bool {
 	return !os.IsNotExist(err)
 }
 
-/*
-	CheckPath confirms that the path provided has not been made to
-	perform a traversal attack.
-*/
-func CheckPath(destination, filename string) (err error) {
-	destination, _ = filepath.Abs(destination)
-	dest := filepath.Join(destination, filename)
-	if !strings.HasPrefix(dest, destination) {
-		return &IllegalPathError{dest, filename}
-	}
-	return nil
-}
-
 /*
 	IsSymlink returns true if the file is a symlink.
 */
@@ -164,31 +262,50 @@ func Mkdir(path string, mode os.FileMode) error {
 }
 
 /*
-	WriteFile writes a file to the destination path.
+	WriteFile writes a file to the destination path, replacing any file
+	that is already there. The file is created with the permissions of
+	mode, masked by the umask of the process. Extractions set the mode
+	of each entry without the umask afterwards only when
+	Options.PreservePermissions is set.
 */
 func WriteFile(destination string, in io.Reader, mode os.FileMode) (err error) {
-	err = Mkdir(filepath.Dir(destination), 0755)
+	out, err := createFile(destination, mode)
 	if err != nil {
-		return fmt.Errorf("error creating parent directories: %v", err)
-	}
-	out, err := os.Create(destination)
-	if err != nil {
-		return fmt.Errorf("%s: error creating file: %v", destination, err)
+		return err
 	}
 	defer out.Close()
 
-	err = out.Chmod(mode)
+	_, err = io.Copy(out, in)
 	if err != nil {
-		return fmt.Errorf("%s: error setting permissions: %v", destination, err)
+		return fmt.Errorf("%s: error writing file: %w", destination, err)
 	}
+	return out.Close()
+}
 
-	_, err = io.Copy(out, in)
+/*
+	createFile creates the file at the destination path with the
+	permissions of mode, masked by the umask, along with its parent
+	directories, replacing any file already there.
+*/
+func createFile(destination string, mode os.FileMode) (*os.File, error) {
+	err := Mkdir(filepath.Dir(destination), 0755)
 	if err != nil {
-		return fmt.Errorf("%s: error writing file: %v", destination, err)
+		return nil, fmt.Errorf("error creating parent directories: %v", err)
 	}
-	out.Close()
 
-	return nil
+	// An existing file is removed rather than truncated, so that a
+	// symlink or hardlink at the destination is not written through.
+	if fi, err := os.Lstat(destination); err == nil && !fi.IsDir() {
+		err = os.Remove(destination)
+		if err != nil {
+			return nil, fmt.Errorf("%s: error removing existing file: %v", destination, err)
+		}
+	}
+	out, err := os.OpenFile(destination, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return nil, fmt.Errorf("%s: error creating file: %v", destination, err)
+	}
+	return out, nil
 }
 
 /*
@@ -231,7 +348,7 @@ func WriteHardlink(destination, link string) (err error) {
 	}
 	err = os.Link(link, destination)
 	if err != nil {
-		return fmt.Errorf("%s: error creating symlink: %v", destination, err)
+		return fmt.Errorf("%s: error creating hard link: %v", destination, err)
 	}
 	return nil
 }
@@ -1,13 +1,12 @@
 package extract
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
-	"time"
-
-	"github.com/vbauerster/mpb/v7"
 )
 
 func TestExtract(t *testing.T) {
@@ -30,16 +29,113 @@ func TestExtract(t *testing.T) {
 		{format: NewZip(), dest: "Zip", file: "testdata/test.zip", expected: true},
 	} {
 		destDir := filepath.Join(testParent, tc.dest)
-		start := time.Now()
 		u, _ := tc.format.(Extractor)
-		p := mpb.New()
-		err := u.Extract(tc.file, destDir, p, start)
-		if !tc.expected && err != nil {
+		err := u.Extract(tc.file, destDir, nil)
+		if tc.expected && err != nil {
 			t.Errorf("[%d] [%s - %s] expected no error but got %s", i, tc.format, tc.file, err)
 		}
 	}
 }
 
+func TestExtractContext(t *testing.T) {
+	testParent, err := ioutil.TempDir("", "extract_temp")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(testParent)
+
+	files := []string{"testdata/test.tar", "testdata/test.zip", "testdata/test.rar"}
+	res, err := ExtractContext(context.Background(), files, Options{Destination: testParent})
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+	if len(res.Archives) != len(files) {
+		t.Fatalf("expected %d results but got %d", len(files), len(res.Archives))
+	}
+	for i, r := range res.Archives {
+		if r.Archive != files[i] {
+			t.Errorf("[%d] expected archive %s but got %s", i, files[i], r.Archive)
+		}
+		if r.Err != nil {
+			t.Errorf("[%d] %s: expected no error but got %s", i, r.Archive, r.Err)
+		}
+	}
+	if !FileExists(filepath.Join(testParent, "test", "0dmnf3", "f2eeblv6")) {
+		t.Errorf("expected archive contents to be extracted")
+	}
+	for i, format := range []string{"tar", "zip", "rar"} {
+		r := res.Archives[i]
+		if r.Format != format {
+			t.Errorf("[%d] expected format %s but got %s", i, format, r.Format)
+		}
+		if r.Entries == 0 || r.Bytes == 0 {
+			t.Errorf("[%d] expected entries and bytes to be counted but got %d and %d", i, r.Entries, r.Bytes)
+		}
+	}
+}
+
+func TestExtractContextFailures(t *testing.T) {
+	testParent, err := ioutil.TempDir("", "extract_temp")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(testParent)
+
+	files := []string{"testdata/test.tar", "testdata/test.txt", "testdata/missing.zip"}
+	res, err := ExtractContext(context.Background(), files, Options{Destination: testParent})
+	if err == nil {
+		t.Fatalf("expected error but got nil")
+	}
+	if res.Archives[0].Err != nil {
+		t.Errorf("expected %s to extract but got %s", files[0], res.Archives[0].Err)
+	}
+	for _, r := range res.Archives[1:] {
+		if r.Err == nil {
+			t.Errorf("%s: expected error but got nil", r.Archive)
+		}
+	}
+}
+
+func TestExtractContextCancelled(t *testing.T) {
+	testParent, err := ioutil.TempDir("", "extract_temp")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(testParent)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files := []string{"testdata/test.tar", "testdata/test.zip"}
+	res, err := ExtractContext(ctx, files, Options{Destination: testParent})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled but got %v", err)
+	}
+	for i, r := range res.Archives {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("[%d] %s: expected context.Canceled but got %v", i, r.Archive, r.Err)
+		}
+	}
+}
+
+func TestCtxReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in, err := os.Open("testdata/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	r := ctxReader{ctx, in}
+	if _, err := r.Read(make([]byte, 10)); err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+	cancel()
+	if _, err := r.Read(make([]byte, 10)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled but got %v", err)
+	}
+}
+
 func TestMultipleTopLevels(t *testing.T) {
 	for i, tc := range []struct {
 		set    []string
@@ -0,0 +1,89 @@
+package extract
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+/*
+	Filter selects the entries of an archive that are extracted. Entries
+	are matched by their names in the archive, with any leading "/"
+	removed, and are extracted when they match one of the Include or
+	IncludeRegexp patterns, if any are given, none of the Exclude or
+	ExcludeRegexp patterns, and Match, if it is set.
+
+	The glob patterns use the syntax of path.Match. A pattern with no
+	"/" matches any element of the name, so "*.log" matches "a/b.log",
+	while one with a "/", including a leading one, matches the name or
+	any of its parents, so "/bin" and "bin/*" match "bin/tool" but not
+	"src/bin/tool". Regular expressions are matched against the whole
+	name. Filtered entries are skipped before any of their data is
+	written.
+*/
+type Filter struct {
+	Include []string
+	Exclude []string
+
+	IncludeRegexp []*regexp.Regexp
+	ExcludeRegexp []*regexp.Regexp
+
+	// Match is called with each entry that the patterns select.
+	Match func(f File) bool
+}
+
+/*
+	selects reports whether the entry f, named name in its archive, is
+	extracted by the filter.
+*/
+func (fl *Filter) selects(name string, f File) bool {
+	name = strings.Trim(path.Clean("/"+name), "/")
+
+	if len(fl.Include) > 0 || len(fl.IncludeRegexp) > 0 {
+		if !matchGlobs(fl.Include, name) && !matchRegexps(fl.IncludeRegexp, name) {
+			return false
+		}
+	}
+	if matchGlobs(fl.Exclude, name) || matchRegexps(fl.ExcludeRegexp, name) {
+		return false
+	}
+	return fl.Match == nil || fl.Match(f)
+}
+
+// matchGlobs reports whether name matches any of the glob patterns.
+func matchGlobs(patterns []string, name string) bool {
+	elems := strings.Split(name, "/")
+	for _, p := range patterns {
+		anchored := strings.Contains(p, "/")
+		p = strings.Trim(p, "/")
+		if !anchored {
+			for _, e := range elems {
+				if ok, _ := path.Match(p, e); ok {
+					return true
+				}
+			}
+			continue
+		}
+		for i := range elems {
+			if ok, _ := path.Match(p, strings.Join(elems[:i+1], "/")); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchRegexps reports whether name matches any of the expressions.
+func matchRegexps(res []*regexp.Regexp, name string) bool {
+	for _, re := range res {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// selected reports whether the entry f, named name, is to be extracted.
+func (j *job) selected(name string, f File) bool {
+	return j.opts.Filter.selects(name, f)
+}
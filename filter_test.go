@@ -0,0 +1,81 @@
+package extract
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	for i, tc := range []struct {
+		filter Filter
+		name   string
+		expect bool
+	}{
+		{filter: Filter{}, name: "a/b", expect: true},
+		{filter: Filter{Include: []string{"*.conf"}}, name: "etc/app.conf", expect: true},
+		{filter: Filter{Include: []string{"*.conf"}}, name: "etc/app.yaml"},
+		{filter: Filter{Include: []string{"bin"}}, name: "src/bin/tool", expect: true},
+		{filter: Filter{Include: []string{"/bin"}}, name: "src/bin/tool"},
+		{filter: Filter{Include: []string{"/bin"}}, name: "bin/tool", expect: true},
+		{filter: Filter{Include: []string{"bin/*"}}, name: "/bin/tool/x", expect: true},
+		{filter: Filter{Include: []string{"bin/"}}, name: "./bin/tool", expect: true},
+		{filter: Filter{Exclude: []string{"*.log"}}, name: "var/x.log"},
+		{filter: Filter{Exclude: []string{"var"}}, name: "var/x/y"},
+		{filter: Filter{Include: []string{"/var"}, Exclude: []string{"*.log"}}, name: "var/x.log"},
+		{filter: Filter{IncludeRegexp: []*regexp.Regexp{regexp.MustCompile(`^bin/[^/]+$`)}}, name: "bin/tool", expect: true},
+		{filter: Filter{IncludeRegexp: []*regexp.Regexp{regexp.MustCompile(`^bin/[^/]+$`)}}, name: "bin/x/tool"},
+		{filter: Filter{ExcludeRegexp: []*regexp.Regexp{regexp.MustCompile(`\.tmp$`)}}, name: "a.tmp"},
+		{filter: Filter{Include: []string{"a"}, IncludeRegexp: []*regexp.Regexp{regexp.MustCompile(`^b`)}}, name: "b", expect: true},
+		{filter: Filter{Match: func(f File) bool { return false }}, name: "a"},
+	} {
+		if got := tc.filter.selects(tc.name, File{}); got != tc.expect {
+			t.Errorf("[%d] %s: expected %v but got %v", i, tc.name, tc.expect, got)
+		}
+	}
+}
+
+func TestExtractFiltered(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_filter")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	for _, file := range []string{"testdata/test.tar.gz", "testdata/test.zip", "testdata/test.rar"} {
+		dest := filepath.Join(parent, filepath.Base(file))
+		var seen int
+		opts := Options{
+			Destination: dest,
+			Filter: Filter{
+				Include: []string{"/test/xeso", "/test/80nj"},
+				Exclude: []string{"0yx5vo0"},
+				Match: func(f File) bool {
+					seen++
+					return true
+				},
+			},
+		}
+		res, err := ExtractContext(context.Background(), []string{file}, opts)
+		if err != nil {
+			t.Fatalf("%s: expected no error but got %v", file, err)
+		}
+
+		for _, name := range []string{"test/xeso/bw7yzbpm", "test/80nj"} {
+			if !FileExists(filepath.Join(dest, name)) {
+				t.Errorf("%s: expected %s to be extracted", file, name)
+			}
+		}
+		for _, name := range []string{"test/xeso/0yx5vo0", "test/0dmnf3"} {
+			if FileExists(filepath.Join(dest, name)) {
+				t.Errorf("%s: expected %s to be filtered out", file, name)
+			}
+		}
+		if entries := res.Archives[0].Entries; entries != seen {
+			t.Errorf("%s: expected the %d entries matched to be extracted but got %d", file, seen, entries)
+		}
+	}
+}
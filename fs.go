@@ -0,0 +1,578 @@
+package extract
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zip"
+)
+
+/*
+	FS returns a read-only fs.FS backed by the index of the archive
+	found at path. The returned value also implements fs.ReadDirFS,
+	fs.StatFS and io.Closer, and can be handed directly to fs.WalkDir,
+	fs.Glob, template.ParseFS or http.FS.
+
+	Zip archives are read using random access through the central
+	directory. Tar, TarGz and Rar archives are indexed once up front;
+	entries in a plain tar are then read by seeking to their offset,
+	while compressed streams are re-read up to the requested entry on
+	demand.
+*/
+func FS(path string) (fs.FS, error) {
+	iface, err := GetFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f := iface.(type) {
+	case *Zip:
+		return newZipFS(path)
+	case *TarGz:
+		return newTarFS(path, func() *Tar {
+			tgz := NewTarGz()
+			tgz.wrapReader()
+			return tgz.Tar
+		})
+	case *TarBz2:
+		return newTarFS(path, func() *Tar {
+			tbz := NewTarBz2()
+			tbz.wrapReader()
+			return tbz.Tar
+		})
+	case *TarXz:
+		return newTarFS(path, func() *Tar {
+			txz := NewTarXz()
+			txz.wrapReader()
+			return txz.Tar
+		})
+	case *Tar:
+		return newTarFS(path, NewTar)
+	case *Rar:
+		return newRarFS(path)
+	default:
+		return nil, fmt.Errorf("%s: %T is not an archive format", path, f)
+	}
+}
+
+/*
+	archiveFS is an fs.FS over the index of an archive. The index is
+	built once and is not modified afterwards, so the FS is safe for
+	concurrent use.
+*/
+type archiveFS struct {
+	path    string
+	entries map[string]*fsEntry
+	open    func(e *fsEntry) (io.ReadCloser, error)
+	closer  io.Closer
+}
+
+/*
+	fsEntry is a single file or directory within an archiveFS. Entries
+	for directories that are implied by file paths, but not present in
+	the archive, are synthesised when the index is built.
+*/
+type fsEntry struct {
+	name     string
+	info     fs.FileInfo
+	children []*fsEntry
+
+	// index is the position of the entry within the archive, and
+	// offset the position of its data when it can be read directly.
+	index  int
+	offset int64
+	zf     *zip.File
+}
+
+func newArchiveFS(path string) *archiveFS {
+	root := &fsEntry{name: ".", offset: -1}
+	root.info = dirInfo{e: root}
+	return &archiveFS{
+		path:    path,
+		entries: map[string]*fsEntry{".": root},
+	}
+}
+
+/*
+	add records an entry in the index, creating any parent directories
+	that are missing. Names that cannot be represented in an fs.FS, such
+	as those containing "..", are ignored.
+*/
+func (a *archiveFS) add(name string, info fs.FileInfo) *fsEntry {
+	name = strings.TrimPrefix(strings.Replace(name, `\`, "/", -1), "/")
+	name = path.Clean(name)
+	if name == "." || !fs.ValidPath(name) {
+		return nil
+	}
+
+	e, ok := a.entries[name]
+	if !ok {
+		e = &fsEntry{name: name, offset: -1}
+		a.entries[name] = e
+		parent := a.dir(path.Dir(name))
+		parent.children = append(parent.children, e)
+	}
+	if info.IsDir() {
+		e.info = dirInfo{e: e}
+		if mt := info.ModTime(); !mt.IsZero() {
+			e.info = dirInfo{e: e, modTime: mt, mode: info.Mode()}
+		}
+	} else {
+		e.info = namedInfo{info, path.Base(name)}
+	}
+	return e
+}
+
+/*
+	dir returns the directory entry for name, creating it along with
+	any missing parents.
+*/
+func (a *archiveFS) dir(name string) *fsEntry {
+	if e, ok := a.entries[name]; ok {
+		return e
+	}
+	e := &fsEntry{name: name, offset: -1}
+	e.info = dirInfo{e: e}
+	a.entries[name] = e
+	parent := a.dir(path.Dir(name))
+	parent.children = append(parent.children, e)
+	return e
+}
+
+/*
+	finish sorts the children of every directory so that ReadDir returns
+	entries in the order required by fs.ReadDirFS.
+*/
+func (a *archiveFS) finish() {
+	for _, e := range a.entries {
+		sort.Slice(e.children, func(i, j int) bool {
+			return e.children[i].name < e.children[j].name
+		})
+	}
+}
+
+func (a *archiveFS) lookup(op, name string) (*fsEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := a.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+// Open implements fs.FS.
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	e, err := a.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.info.IsDir() {
+		return &fsDir{e: e}, nil
+	}
+	return &fsFile{e: e, fsys: a}, nil
+}
+
+// Stat implements fs.StatFS.
+func (a *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	e, err := a.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.info, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (a *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := a.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	return dirEntries(e.children), nil
+}
+
+// Close releases the archive file held open by the FS, if any.
+func (a *archiveFS) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+/*
+	newZipFS indexes a Zip archive from its central directory. The
+	archive is kept open so that entries can be read at random.
+*/
+func newZipFS(filename string) (*archiveFS, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", filename, err)
+	}
+	fInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error getting file info for %s: %v", filename, err)
+	}
+
+	z := NewZip()
+	err = z.Open(f, fInfo.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error opening archive for reading: %v", err)
+	}
+
+	a := newArchiveFS(filename)
+	for i, zf := range z.zr.File {
+		e := a.add(zf.Name, zf.FileInfo())
+		if e == nil {
+			continue
+		}
+		e.index = i
+		e.zf = zf
+	}
+	a.finish()
+	a.closer = f
+	a.open = func(e *fsEntry) (io.ReadCloser, error) {
+		return e.zf.Open()
+	}
+	return a, nil
+}
+
+/*
+	newTarFS indexes a tar archive, with newTar returning a Tar that
+	has any decompression wrapper already set. When the archive is not
+	wrapped, the data offset of each regular entry is recorded so that
+	it can be read with a single seek.
+*/
+func newTarFS(filename string, newTar func() *Tar) (*archiveFS, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", filename, err)
+	}
+
+	t := newTar()
+	seekable := t.readerWrapFn == nil
+	cr := &countReader{r: f}
+	err = t.Open(cr)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	defer t.Close()
+
+	a := newArchiveFS(filename)
+	for i := 0; ; i++ {
+		file, err := t.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("issue scanning tar file listings: %v", err)
+		}
+		h := file.Header.(*tar.Header)
+		e := a.add(h.Name, file.FileInfo)
+		if e == nil {
+			continue
+		}
+		e.index = i
+		if seekable && isRegular(h) {
+			e.offset = cr.n
+		}
+	}
+	a.finish()
+
+	if !seekable {
+		f.Close()
+		a.open = func(e *fsEntry) (io.ReadCloser, error) {
+			return openTarEntry(filename, newTar(), e.index)
+		}
+		return a, nil
+	}
+
+	a.closer = f
+	a.open = func(e *fsEntry) (io.ReadCloser, error) {
+		if e.offset < 0 {
+			return openTarEntry(filename, newTar(), e.index)
+		}
+		return ioutil.NopCloser(io.NewSectionReader(f, e.offset, e.info.Size())), nil
+	}
+	return a, nil
+}
+
+/*
+	openTarEntry re-reads the tar archive from the start, stopping at the
+	entry at position index.
+*/
+func openTarEntry(filename string, t *Tar, index int) (io.ReadCloser, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", filename, err)
+	}
+	err = t.Open(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for i := 0; ; i++ {
+		file, err := t.Read()
+		if err != nil {
+			t.Close()
+			f.Close()
+			return nil, fmt.Errorf("%s: seeking to entry %d: %v", filename, index, err)
+		}
+		if i == index {
+			return &entryReadCloser{file, func() error {
+				t.Close()
+				return f.Close()
+			}}, nil
+		}
+	}
+}
+
+/*
+	newRarFS indexes a Rar archive. Rar archives cannot be read at
+	random, so each entry is opened by reading the archive up to it.
+*/
+func newRarFS(filename string) (*archiveFS, error) {
+	rar := NewRar()
+	err := rar.OpenRarFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open rar file for reading: %v", err)
+	}
+	defer rar.Close()
+
+	a := newArchiveFS(filename)
+	for i := 0; ; i++ {
+		file, err := rar.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("issue scanning rar file listings: %v", err)
+		}
+		e := a.add(file.Name(), file.FileInfo)
+		if e == nil {
+			continue
+		}
+		e.index = i
+	}
+	a.finish()
+
+	a.open = func(e *fsEntry) (io.ReadCloser, error) {
+		rar := NewRar()
+		err := rar.OpenRarFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open rar file for reading: %v", err)
+		}
+		for i := 0; ; i++ {
+			file, err := rar.Read()
+			if err != nil {
+				rar.Close()
+				return nil, fmt.Errorf("%s: seeking to entry %d: %v", filename, e.index, err)
+			}
+			if i == e.index {
+				return &entryReadCloser{file, rar.Close}, nil
+			}
+		}
+	}
+	return a, nil
+}
+
+/*
+	fsFile is an open regular file within an archiveFS. The underlying
+	reader is opened lazily, and Seek is supported by reopening the
+	entry and discarding data where the format does not allow direct
+	access, so that the file can be served by http.FS.
+*/
+type fsFile struct {
+	e    *fsEntry
+	fsys *archiveFS
+
+	rc  io.ReadCloser
+	pos int64 // position of rc within the entry
+	off int64 // position requested by the caller
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) { return f.e.info, nil }
+
+func (f *fsFile) Read(b []byte) (n int, err error) {
+	if f.rc == nil || f.off < f.pos {
+		if f.rc != nil {
+			f.rc.Close()
+		}
+		f.rc, err = f.fsys.open(f.e)
+		if err != nil {
+			f.rc = nil
+			return 0, &fs.PathError{Op: "read", Path: f.e.name, Err: err}
+		}
+		f.pos = 0
+	}
+	if f.off > f.pos {
+		_, err = io.CopyN(ioutil.Discard, f.rc, f.off-f.pos)
+		if err != nil {
+			return 0, err
+		}
+		f.pos = f.off
+	}
+	n, err = f.rc.Read(b)
+	f.pos += int64(n)
+	f.off = f.pos
+	return n, err
+}
+
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.e.info.Size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.e.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.e.name, Err: fs.ErrInvalid}
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *fsFile) Close() error {
+	if f.rc == nil {
+		return nil
+	}
+	rc := f.rc
+	f.rc = nil
+	return rc.Close()
+}
+
+// fsDir is an open directory within an archiveFS.
+type fsDir struct {
+	e   *fsEntry
+	off int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) { return d.e.info, nil }
+func (d *fsDir) Close() error               { return nil }
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.e.name, Err: fmt.Errorf("is a directory")}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *fsDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	rest := d.e.children[d.off:]
+	if count > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && len(rest) > count {
+		rest = rest[:count]
+	}
+	d.off += len(rest)
+	return dirEntries(rest), nil
+}
+
+func dirEntries(es []*fsEntry) []fs.DirEntry {
+	list := make([]fs.DirEntry, len(es))
+	for i, e := range es {
+		list[i] = dirEntry{e.info}
+	}
+	return list
+}
+
+// dirEntry adapts an fs.FileInfo to fs.DirEntry.
+type dirEntry struct {
+	fs.FileInfo
+}
+
+func (d dirEntry) Type() fs.FileMode          { return d.Mode().Type() }
+func (d dirEntry) Info() (fs.FileInfo, error) { return d.FileInfo, nil }
+
+// namedInfo overrides the name of an fs.FileInfo with its base name.
+type namedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (ni namedInfo) Name() string { return ni.name }
+
+/*
+	dirInfo describes a directory in an archiveFS. Directories that are
+	only implied by the paths of other entries have no mode or time set.
+*/
+type dirInfo struct {
+	e       *fsEntry
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+func (di dirInfo) Name() string       { return path.Base(di.e.name) }
+func (di dirInfo) Size() int64        { return 0 }
+func (di dirInfo) ModTime() time.Time { return di.modTime }
+func (di dirInfo) IsDir() bool        { return true }
+func (di dirInfo) Sys() interface{}   { return nil }
+
+func (di dirInfo) Mode() fs.FileMode {
+	if di.mode == 0 {
+		return fs.ModeDir | 0555
+	}
+	return fs.ModeDir | di.mode.Perm()
+}
+
+/*
+	entryReadCloser reads from the File of a single archive entry and
+	releases the archive reader positioned at it on Close.
+*/
+type entryReadCloser struct {
+	File
+	close func() error
+}
+
+func (e *entryReadCloser) Close() error {
+	e.File.Close()
+	return e.close()
+}
+
+/*
+	countReader counts the bytes read through it, which is used to find
+	the offset of entries within an uncompressed tar stream.
+*/
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(b []byte) (n int, err error) {
+	n, err = c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+/*
+	isRegular reports whether the tar header describes a regular file
+	whose data is stored contiguously in the archive.
+*/
+func isRegular(h *tar.Header) bool {
+	if h.Typeflag != tar.TypeReg && h.Typeflag != tar.TypeRegA {
+		return false
+	}
+	for k := range h.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package extract
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	for i, file := range []string{
+		"testdata/test.tar",
+		"testdata/test.tar.gz",
+		"testdata/test.tgz",
+		"testdata/test.zip",
+		"testdata/test.rar",
+	} {
+		fsys, err := FS(file)
+		if err != nil {
+			t.Fatalf("[%d] %s: unexpected error: %v", i, file, err)
+		}
+
+		err = fstest.TestFS(fsys, "test/80nj", "test/0dmnf3/f2eeblv6", "test/xeso/bw7yzbpm")
+		if err != nil {
+			t.Errorf("[%d] %s: %v", i, file, err)
+		}
+
+		got, err := fs.ReadFile(fsys, "test/0dmnf3/f2eeblv6")
+		if err != nil {
+			t.Fatalf("[%d] %s: reading file: %v", i, file, err)
+		}
+		want, err := ioutil.ReadFile("testdata/test/0dmnf3/f2eeblv6")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("[%d] %s: file contents differ from testdata", i, file)
+		}
+
+		fsys.(io.Closer).Close()
+	}
+}
+
+func TestFSNotArchive(t *testing.T) {
+	for _, file := range []string{"testdata/test.gz", "testdata/test.txt"} {
+		if _, err := FS(file); err == nil {
+			t.Errorf("%s: expected error but got nil", file)
+		}
+	}
+}
+
+func TestFSSeek(t *testing.T) {
+	fsys, err := FS("testdata/test.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsys.Open("test/80nj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want, err := ioutil.ReadFile("testdata/test/80nj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := f.(io.ReadSeeker)
+	if n, _ := s.Seek(0, io.SeekEnd); n != int64(len(want)) {
+		t.Fatalf("expected size %d but got %d", len(want), n)
+	}
+	if _, err := s.Seek(100, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want[100:]) {
+		t.Errorf("contents after seek differ from testdata")
+	}
+}
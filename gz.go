@@ -3,15 +3,9 @@ package extract
 import (
 	"compress/gzip"
 	"fmt"
-	"io"
 	"os"
-	"path/filepath"
-	"sync/atomic"
-	"time"
 
-	"github.com/Galzzly/extract/v2/internal/magic"
 	"github.com/klauspost/pgzip"
-	"github.com/vbauerster/mpb/v7"
 )
 
 type Gz struct {
@@ -23,59 +17,63 @@ type Gz struct {
 	against the magic numbers for GZip. If the file is a GZip
 	the function will not return any error.
 */
-func (gz *Gz) CheckFormat(filename string) error {
-	l := atomic.LoadUint32(&readLimit)
-
-	// Get the header of filename
-	h, err := GetFileHeader(filename, l)
+func (*Gz) CheckFormat(filename string) error {
+	ok, err := detectFile(filename, isGz)
 	if err != nil {
 		return fmt.Errorf("problem looking at %s", filename)
 	}
-	mu.Lock()
-	defer mu.Unlock()
-
-	// Check if the file is a GZip file
-	var m = newMime("Gzip", magic.Gz)
-	if !m.detector(h, l) {
+	if !ok {
 		return fmt.Errorf("%s is not a gzip file", filename)
 	}
 	return nil
 }
 
+// isGz reports whether s is the start of a gzip file.
+func isGz(s *Sniff) bool {
+	return s.Compression == "gz"
+}
+
 /*
 	Extract will extract the file sent to the function
 */
-func (gz *Gz) Extract(filename, destination string, p *mpb.Progress, start time.Time) (err error) {
-	b := AddNewBar(p, filename, start)
-	// Open the file in filename. We can assume if you've got this
-	// far that the file exists.
-	f, _ := os.Open(filename)
-	defer f.Close()
+func (gz *Gz) Extract(filename, destination string, p ProgressReporter) (err error) {
+	return extractArchive(gz, filename, destination, p)
+}
 
-	fo := filepath.Join(destination, f.Name())
-	// Open the destination file for writing
-	out, err := os.Create(fo)
+func (gz *Gz) extract(j *job, filename, destination string) (err error) {
+	in, err := os.Open(filename)
 	if err != nil {
-		b.Abort(true)
-		return err
+		return fmt.Errorf("problem opening %s: %v", filename, err)
 	}
+	defer in.Close()
 
 	// Open the Reader
-	r, err := pgzip.NewReader(f)
+	r, err := pgzip.NewReader(j.source(in))
 	if err != nil {
-		b.Abort(true)
 		return err
 	}
 	defer r.Close()
 
-	// Write out the file
-	_, err = io.Copy(out, r)
+	// Write out the file, named after the bundle without its extension
+	name := GetFileName(filename)
+	f := File{FileInfo: streamInfo{name, r.ModTime}, ReadCloser: r}
+	if !j.selected(name, f) {
+		return nil
+	}
+	name, ok := j.remap(name)
+	if !ok {
+		return nil
+	}
+	out, err := j.resolve(destination, name)
 	if err != nil {
-		b.Abort(true)
 		return err
 	}
-	b.SetTotal(1, true)
-	return
+	return j.writeFile(out, f)
+}
+
+func init() {
+	RegisterFormat("gz", isGz, func() Extractor { return NewGz() }, 0)
+	RegisterExtensions("gz", ".gz")
 }
 
 func NewGz() *Gz {
@@ -0,0 +1,273 @@
+package extract
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/pgzip"
+	"github.com/ulikunitz/xz"
+)
+
+// tarTrailer is the size of the two zero blocks that end a tar archive.
+const tarTrailer = 2 * 512
+
+/*
+	TestResult reports the outcome of testing an archive with Test.
+	Entries is the number of entries read, and Bytes the total size of
+	their data once decompressed.
+*/
+type TestResult struct {
+	Archive  string
+	Entries  int
+	Bytes    int64
+	Failures []*CorruptEntryError
+}
+
+/*
+	Err returns the failures of the archive joined together, or nil
+	when every entry was read in full.
+*/
+func (r *TestResult) Err() error {
+	errs := make([]error, len(r.Failures))
+	for i, f := range r.Failures {
+		errs[i] = f
+	}
+	return errors.Join(errs...)
+}
+
+/*
+	Test reads every entry of the archive at path in full, discarding
+	the data, to check that it can be extracted. The checksums of zip
+	and rar entries, and the trailers of gzip streams, are verified
+	as they are read, and tar archives that end early are reported.
+
+	Each entry that fails is recorded in the result with the offset of
+	its data, after which zip archives carry on with the next entry, as
+	they can be read at random, while other archives stop. The error is
+	only set when the archive cannot be opened at all.
+*/
+func Test(path string) (*TestResult, error) {
+	iface, err := GetFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("problem opening %s: %v", path, err)
+	}
+	defer f.Close()
+
+	r := &TestResult{Archive: path}
+	switch a := iface.(type) {
+	case *Zip:
+		err = r.zip(f, a)
+	case *TarGz:
+		var gzr *pgzip.Reader
+		gzr, err = pgzip.NewReader(f)
+		if err == nil {
+			defer gzr.Close()
+			err = r.tar(gzr)
+		}
+	case *TarBz2:
+		var bzr *bzip2.Reader
+		bzr, err = bzip2.NewReader(f, nil)
+		if err == nil {
+			defer bzr.Close()
+			err = r.tar(bzr)
+		}
+	case *TarXz:
+		var xzr *xz.Reader
+		xzr, err = xz.NewReader(f)
+		if err == nil {
+			err = r.tar(xzr)
+		}
+	case *Tar:
+		err = r.tar(f)
+	case *Rar:
+		err = r.rar(path, a)
+	case *Gz:
+		var gzr *pgzip.Reader
+		gzr, err = pgzip.NewReader(f)
+		if err == nil {
+			defer gzr.Close()
+			err = r.single(GetFileName(path), gzr)
+		}
+	case *Bz2:
+		var bzr *bzip2.Reader
+		bzr, err = bzip2.NewReader(f, nil)
+		if err == nil {
+			defer bzr.Close()
+			err = r.single(GetFileName(path), bzr)
+		}
+	default:
+		err = fmt.Errorf("%T cannot be tested", a)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return r, nil
+}
+
+// fail records the failure of the entry name, whose data is at offset.
+func (r *TestResult) fail(name string, offset int64, err error) {
+	r.Failures = append(r.Failures, &CorruptEntryError{Filename: name, Offset: offset, Err: err})
+}
+
+// read reads the data of an entry in full, counting it.
+func (r *TestResult) read(in io.Reader) error {
+	n, err := io.Copy(ioutil.Discard, in)
+	r.Bytes += n
+	if err == nil {
+		r.Entries++
+	}
+	return err
+}
+
+/*
+	zip tests each entry of a zip archive, whose checksum is verified
+	by the reader once the entry is read to the end.
+*/
+func (r *TestResult) zip(f *os.File, z *Zip) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	err = z.Open(f, fi.Size())
+	if err != nil {
+		return fmt.Errorf("error opening archive for reading: %v", err)
+	}
+	defer z.Close()
+
+	for _, zf := range z.zr.File {
+		offset, err := zf.DataOffset()
+		if err != nil {
+			r.fail(zf.Name, -1, err)
+			continue
+		}
+		rc, err := zf.Open()
+		if err == nil {
+			err = r.read(rc)
+			rc.Close()
+		}
+		if err != nil {
+			r.fail(zf.Name, offset, err)
+		}
+	}
+	return nil
+}
+
+/*
+	tar tests a tar archive read from in, the offsets being within the
+	decompressed stream for a compressed tar. Once the archive ends, any
+	compression layer is read to the end so that its trailer is checked.
+	An archive that stops at the end of an entry without the zero blocks
+	that mark its end is reported as truncated.
+*/
+func (r *TestResult) tar(in io.Reader) error {
+	tr := &tailReader{r: in}
+	t := NewTar()
+	if err := t.Open(tr); err != nil {
+		return err
+	}
+	defer t.Close()
+
+	for {
+		f, err := t.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			r.fail("", tr.n, err)
+			return nil
+		}
+		h := f.Header.(*tar.Header)
+		offset := tr.n
+		if err := r.read(f); err != nil {
+			r.fail(h.Name, offset, err)
+			return nil
+		}
+	}
+
+	if !tr.zeros() {
+		r.fail("", tr.n, fmt.Errorf("truncated tar archive: %w", io.ErrUnexpectedEOF))
+		return nil
+	}
+	if _, err := io.Copy(ioutil.Discard, in); err != nil {
+		r.fail("", tr.n, err)
+	}
+	return nil
+}
+
+/*
+	rar tests each entry of a rar archive, whose checksum is verified
+	by the reader once the entry is read to the end. The offsets of rar
+	entries are not known.
+*/
+func (r *TestResult) rar(path string, rar *Rar) error {
+	err := rar.OpenRarFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to open rar file for reading: %v", err)
+	}
+	defer rar.Close()
+
+	for {
+		f, err := rar.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			r.fail("", -1, err)
+			return nil
+		}
+		if err := r.read(f); err != nil {
+			r.fail(f.Name(), -1, err)
+			return nil
+		}
+	}
+}
+
+/*
+	single tests a compressed file that holds the single file name, the
+	trailer of the compression being checked once it is read.
+*/
+func (r *TestResult) single(name string, in io.Reader) error {
+	if err := r.read(in); err != nil {
+		r.fail(name, 0, err)
+	}
+	return nil
+}
+
+/*
+	tailReader counts the bytes read through it, keeping the last of
+	them, so that the end of a tar archive can be checked.
+*/
+type tailReader struct {
+	r    io.Reader
+	n    int64
+	tail []byte
+}
+
+func (t *tailReader) Read(b []byte) (int, error) {
+	n, err := t.r.Read(b)
+	t.n += int64(n)
+	if n >= tarTrailer {
+		t.tail = append(t.tail[:0], b[n-tarTrailer:n]...)
+		return n, err
+	}
+	t.tail = append(t.tail, b[:n]...)
+	if len(t.tail) > tarTrailer {
+		t.tail = append(t.tail[:0], t.tail[len(t.tail)-tarTrailer:]...)
+	}
+	return n, err
+}
+
+// zeros reports whether the bytes read ended with the tar trailer.
+func (t *tailReader) zeros() bool {
+	return len(t.tail) == tarTrailer && isZeros(t.tail)
+}
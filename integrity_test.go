@@ -0,0 +1,120 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTest(t *testing.T) {
+	for _, file := range []string{
+		"testdata/test.tar",
+		"testdata/test.tar.gz",
+		"testdata/test.zip",
+		"testdata/test.rar",
+		"testdata/test.gz",
+		"testdata/test.bz2",
+	} {
+		res, err := Test(file)
+		if err != nil {
+			t.Fatalf("%s: expected no error but got %v", file, err)
+		}
+		if err := res.Err(); err != nil {
+			t.Errorf("%s: expected no failures but got %v", file, err)
+		}
+		if res.Entries == 0 || res.Bytes == 0 {
+			t.Errorf("%s: expected entries to be read but got %d entries of %d bytes", file, res.Entries, res.Bytes)
+		}
+	}
+}
+
+func TestTestCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract_test")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dir)
+
+	// corrupt writes a copy of the test file with the byte at offset
+	// flipped, counting negative offsets from the end.
+	corrupt := func(name string, offset int) string {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset < 0 {
+			offset += len(data)
+		}
+		data[offset] ^= 0xff
+		out := filepath.Join(dir, "corrupt-"+name)
+		if err := ioutil.WriteFile(out, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	truncate := func(name string, size int) string {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := filepath.Join(dir, "truncated-"+name)
+		if err := ioutil.WriteFile(out, data[:size], 0644); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	zipData, err := ioutil.ReadFile("testdata/test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipEntry := bytes.Index(zipData, []byte("test/80nj")) + len("test/80nj") + 100
+
+	// A tar that stops after an entry, without the blocks marking its end.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4})
+	tw.Write([]byte("data"))
+	tw.Flush()
+	unterminated := filepath.Join(dir, "unterminated.tar")
+	if err := ioutil.WriteFile(unterminated, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range []struct {
+		file   string
+		entry  string
+		offset bool
+		expect string
+	}{
+		{file: corrupt("test.zip", zipEntry), entry: "test/80nj", offset: true, expect: "checksum"},
+		{file: corrupt("test.gz", -6), entry: "corrupt-test", offset: true, expect: "checksum"},
+		{file: corrupt("test.tar.gz", -6), offset: true, expect: "checksum"},
+		{file: corrupt("test.rar", 3000), expect: "checksum"},
+		{file: truncate("test.tar", 12800), entry: "test/0dmnf3/f2eeblv6", offset: true, expect: io.ErrUnexpectedEOF.Error()},
+		{file: unterminated, offset: true, expect: "truncated"},
+	} {
+		res, err := Test(tc.file)
+		if err != nil {
+			t.Fatalf("[%d] %s: expected no error but got %v", i, tc.file, err)
+		}
+		if len(res.Failures) != 1 {
+			t.Fatalf("[%d] %s: expected a failure but got %v", i, tc.file, res.Failures)
+		}
+		f := res.Failures[0]
+		if tc.entry != "" && f.Filename != tc.entry {
+			t.Errorf("[%d] %s: expected %s to fail but got %s", i, tc.file, tc.entry, f.Filename)
+		}
+		if tc.offset != (f.Offset >= 0) {
+			t.Errorf("[%d] %s: unexpected offset %d", i, tc.file, f.Offset)
+		}
+		if !strings.Contains(f.Error(), tc.expect) || !IsCorruptEntryError(res.Err()) {
+			t.Errorf("[%d] %s: expected %q but got %v", i, tc.file, tc.expect, f)
+		}
+	}
+}
@@ -13,6 +13,12 @@ var (
 	// Gz matches the gzip file format
 	Gz = prefix([]byte{0x1f, 0x8b})
 
+	// Xz matches the xz file format
+	Xz = prefix([]byte{0xFD, 0x37, 0x7A, 0x58, 0x5A, 0x00})
+
+	// Zstd matches the zstandard file format
+	Zstd = prefix([]byte{0x28, 0xB5, 0x2F, 0xFD})
+
 	// Rar1 and Rar2 match the rar file format
 	Rar1 = prefix([]byte{0x52, 0x61, 0x72, 0x21, 0x1a, 0x07, 0x00})
 	Rar2 = prefix([]byte{0x52, 0x61, 0x72, 0x21, 0x1a, 0x07, 0x01, 0x00})
@@ -38,9 +44,85 @@ var (
 	SevenZ = prefix([]byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C})
 )
 
+// Tar matches the tar file format by the ustar or GNU magic, or for
+// archives written before the magic was added, such as v7 tar, by the
+// checksum of the first header.
 func Tar(raw []byte, limit uint32) bool {
-	return Tar1(raw, limit) || Tar2(raw, limit)
+	return Tar1(raw, limit) || Tar2(raw, limit) || TarChecksum(raw, limit)
+}
+
+// tarBlock is the size of a tar header.
+const tarBlock = 512
+
+// TarChecksum matches a tar header by its fields rather than its
+// magic. The name must be set, the numeric fields must be octal, or
+// base-256 as GNU tar writes large values, and the checksum must match
+// the sum of the header, counted as either unsigned or signed bytes as
+// old implementations differed.
+func TarChecksum(raw []byte, limit uint32) bool {
+	if len(raw) < tarBlock || raw[0] == 0 {
+		return false
+	}
+	h := raw[:tarBlock]
+
+	// The mode and checksum are always written as octal, while the ids,
+	// size and time may also be written in base-256.
+	if !octal(h[100:108], false) || !octal(h[148:156], false) {
+		return false
+	}
+	for _, f := range [][2]int{{108, 116}, {116, 124}, {124, 136}, {136, 148}} {
+		if !octal(h[f[0]:f[1]], true) {
+			return false
+		}
+	}
+
+	want, ok := parseOctal(h[148:156])
+	if !ok {
+		return false
+	}
+	var unsigned, signed int64
+	for i, c := range h {
+		if i >= 148 && i < 156 {
+			c = ' '
+		}
+		unsigned += int64(c)
+		signed += int64(int8(c))
+	}
+	return want == unsigned || want == signed
+}
 
+// octal reports whether the field b of a tar header holds an octal
+// number, padded with spaces and ended by a space or NUL, or one that
+// is empty. When base256 is set, a value in base-256 is also allowed.
+func octal(b []byte, base256 bool) bool {
+	if base256 && len(b) > 0 && b[0]&0x80 != 0 {
+		return true
+	}
+	_, ok := parseOctal(b)
+	return ok || len(bytes.Trim(b, " \x00")) == 0
+}
+
+// parseOctal parses the octal number held in the field b of a tar header.
+func parseOctal(b []byte) (int64, bool) {
+	b = bytes.TrimLeft(b, " ")
+	if i := bytes.IndexAny(b, " \x00"); i >= 0 {
+		// Only padding may follow the number.
+		if len(bytes.Trim(b[i:], " \x00")) != 0 {
+			return 0, false
+		}
+		b = b[:i]
+	}
+	if len(b) == 0 {
+		return 0, false
+	}
+	var n int64
+	for _, c := range b {
+		if c < '0' || c > '7' {
+			return 0, false
+		}
+		n = n<<3 | int64(c-'0')
+	}
+	return n, true
 }
 
 func prefix(sigs ...[]byte) Detector {
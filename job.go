@@ -0,0 +1,461 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	archiveExtractor is implemented by the formats within this package.
+	It allows an archive to be extracted as part of a job, which carries
+	the context and options of the extraction down to each entry.
+*/
+type archiveExtractor interface {
+	extract(j *job, filename, destination string) error
+}
+
+/*
+	job holds the state of the extraction of a single archive.
+*/
+type job struct {
+	ctx      context.Context
+	opts     *Options
+	res      *ArchiveResult
+	progress ProgressReporter
+
+	// consumed counts the bytes read from the archive. It is updated
+	// atomically, as decompressors may read ahead in the background.
+	consumed int64
+
+	// stage is the directory that an atomic extraction is written to,
+	// before being moved into final.
+	stage, final string
+
+	// pending is set while the top level directory of the archive is
+	// chosen once all of its entries are known, with tops holding the
+	// top level elements of those extracted. single is set for a
+	// stream that holds a single compressed file, which is not wrapped.
+	pending, single bool
+	tops            map[string]bool
+
+	// sums holds the checksums of the files written, by name.
+	sums map[string]string
+
+	// deferred holds the entries of a pending dry run, which are
+	// assessed once the top level directory is known.
+	deferred []deferredEntry
+
+	// planned holds the paths that a dry run would write, and links the
+	// targets of the symlinks among them, so that later entries are
+	// checked against them as they would be against those written.
+	planned map[string]os.FileInfo
+	links   map[string]string
+
+	// dirs holds the times of the directories extracted, which are set
+	// once the extraction is complete.
+	dirs []dirTime
+}
+
+// warn records a warning for the archive, which does not stop it.
+func (j *job) warn(format string, args ...interface{}) {
+	j.res.Warnings = append(j.res.Warnings, fmt.Sprintf(format, args...))
+}
+
+// warnOnce records the warning unless it has already been recorded.
+func (j *job) warnOnce(warning string) {
+	for _, w := range j.res.Warnings {
+		if w == warning {
+			return
+		}
+	}
+	j.res.Warnings = append(j.res.Warnings, warning)
+}
+
+func newJob(ctx context.Context, opts *Options, res *ArchiveResult) *job {
+	progress := opts.Progress
+	if progress == nil {
+		progress = NopProgress{}
+	}
+	return &job{
+		ctx:      ctx,
+		opts:     opts,
+		res:      res,
+		progress: progress,
+	}
+}
+
+/*
+	run extracts filename into destination using x, reporting the start
+	and end of the archive to the progress reporter.
+*/
+func (j *job) run(x archiveExtractor, filename, destination string) (err error) {
+	start := time.Now()
+	j.res.Archive = filename
+	j.res.Format = formatName(x)
+	j.res.Destination = destination
+
+	var size int64 = -1
+	if fi, err := os.Stat(filename); err == nil {
+		size = fi.Size()
+	}
+	j.progress.ArchiveStart(filename, size)
+	defer func() {
+		j.finishManifest()
+		j.res.Duration = time.Since(start)
+		j.res.Err = err
+		j.progress.ArchiveDone(filename, err)
+	}()
+
+	destination, err = j.prepare(x, destination)
+	if err != nil {
+		return err
+	}
+
+	return j.staged(destination, func(destination string) error {
+		err := x.extract(j, filename, destination)
+		if err == errSkipEntry {
+			err = nil
+		}
+		if derr := j.setDirTimes(); err == nil {
+			err = derr
+		}
+		return err
+	})
+}
+
+/*
+	fail records err for an archive that could not be started, reporting
+	it as done to the progress reporter.
+*/
+func (j *job) fail(err error) {
+	j.res.Err = err
+	j.progress.ArchiveDone(j.res.Archive, err)
+}
+
+/*
+	source wraps the archive being read so that the bytes consumed from
+	it are counted and reported.
+*/
+func (j *job) source(r io.Reader) io.Reader {
+	return sourceReader{j, r}
+}
+
+// read records n bytes as consumed from the archive.
+func (j *job) read(n int64) {
+	atomic.AddInt64(&j.consumed, n)
+	j.progress.BytesRead(j.res.Archive, n)
+}
+
+// err returns a non-nil error once the job has been cancelled.
+func (j *job) err() error {
+	return j.ctx.Err()
+}
+
+/*
+	resolve returns the path that the entry name is written to within
+	destination, rejecting any that would leave it.
+*/
+func (j *job) resolve(destination, name string) (string, error) {
+	dest, err := securePath(destination, name, j.readlink)
+	if err != nil {
+		if j.opts.DryRun && IsIllegalPathError(err) {
+			j.reject(name, err)
+			return "", errSkipEntry
+		}
+		return "", fmt.Errorf("checking path: %w", err)
+	}
+	if err := j.checkDepth(j.name(dest)); err != nil {
+		return "", err
+	}
+	j.addTop(name)
+	return dest, nil
+}
+
+// name returns the destination path relative to that of the archive.
+func (j *job) name(destination string) string {
+	if root, err := filepath.Abs(j.res.Destination); err == nil {
+		return relName(root, destination)
+	}
+	return destination
+}
+
+/*
+	writeFile writes the file f to the destination path, stopping part
+	way through if the job is cancelled or a limit is reached.
+*/
+func (j *job) writeFile(destination string, f File) error {
+	name := j.name(destination)
+	if max := j.opts.Limits.MaxEntryBytes; max > 0 && f.Size() > max {
+		return &LimitExceededError{Limit: "MaxEntryBytes", Filename: name}
+	}
+	if j.opts.DryRun {
+		return j.plan(destination, f)
+	}
+	destination, ok, err := j.conflict(destination, f.ModTime())
+	if !ok {
+		return err
+	}
+	return j.entry(destination, f.Size(), func() error {
+		write := WriteFile
+		if isSparse(f) {
+			write = WriteSparseFile
+		}
+		jr := &jobReader{j: j, r: f, name: name}
+		if h := j.opts.Checksum; h != 0 {
+			if !h.Available() {
+				return fmt.Errorf("hash %v is not available", h)
+			}
+			jr.hash = h.New()
+		}
+		err := write(destination, jr, j.mode(f))
+		if IsLimitExceededError(err) {
+			os.Remove(destination)
+		}
+		if err == nil {
+			err = j.restore(destination, f)
+		}
+		if err != nil {
+			return err
+		}
+		if jr.hash != nil {
+			j.checksum(destination, jr.hash)
+		}
+		return j.chtimes(destination, f)
+	})
+}
+
+/*
+	mkdir creates the directory f at the destination path. A symlink
+	already at the path to a directory within the destination is kept,
+	with the entries within written through it as with any other, and
+	its directory left as it is. Any other symlink is replaced, as in
+	GNU tar, rather than followed, so that the mode and times of the
+	entry are only set on a directory within the destination.
+*/
+func (j *job) mkdir(destination string, f File) error {
+	if j.opts.DryRun {
+		return j.plan(destination, f)
+	}
+	return j.entry(destination, 0, func() error {
+		if fi, err := os.Lstat(destination); err == nil && IsSymlink(fi) {
+			root, err := filepath.Abs(j.res.Destination)
+			if err != nil {
+				return err
+			}
+			if _, ok, _ := linkedDir(root, destination); ok {
+				return nil
+			}
+			if err := os.Remove(destination); err != nil {
+				return fmt.Errorf("%s: error removing existing symlink: %v", destination, err)
+			}
+		}
+		err := Mkdir(destination, j.mode(f))
+		if err == nil {
+			err = realDir(destination)
+		}
+		if err == nil {
+			err = j.restore(destination, f)
+		}
+		if err != nil {
+			return err
+		}
+		return j.chtimes(destination, f)
+	})
+}
+
+/*
+	symlink creates the symbolic link at the destination path, once its
+	target is allowed by the link policy.
+*/
+func (j *job) symlink(destination, link string, f File) error {
+	root, path := j.res.Destination, destination
+	if filepath.IsAbs(link) {
+		// Absolute targets are checked against where the link will be
+		// once an atomic extraction is moved into place.
+		root, path = j.target(root), j.target(path)
+	}
+	err := checkSymlink(root, path, link, j.opts.Links)
+	if err != nil {
+		return j.reject(j.name(destination), err)
+	}
+	if j.opts.DryRun {
+		return j.planLink(destination, link, f)
+	}
+	destination, ok, err := j.conflict(destination, f.ModTime())
+	if !ok {
+		return err
+	}
+	return j.entry(destination, 0, func() error {
+		err := WriteSymlink(destination, link)
+		if err == nil {
+			err = j.restore(destination, f)
+		}
+		if err != nil {
+			return err
+		}
+		return j.chtimes(destination, f)
+	})
+}
+
+/*
+	hardlink creates the hard link at the destination path to link, the
+	name of an earlier entry in the archive.
+*/
+func (j *job) hardlink(destination, link string, f File) error {
+	target, err := hardlinkTarget(j.res.Destination, destination, link, j.opts.Links)
+	if err != nil {
+		if IsIllegalLinkError(err) {
+			return j.reject(j.name(destination), err)
+		}
+		return err
+	}
+	if j.opts.DryRun {
+		return j.plan(destination, f)
+	}
+	destination, ok, err := j.conflict(destination, f.ModTime())
+	if !ok {
+		return err
+	}
+	return j.entry(destination, 0, func() error {
+		return WriteHardlink(destination, target)
+	})
+}
+
+/*
+	entry writes a single entry to the destination path using write,
+	reporting its progress and counting it once written. An entry whose
+	write returns errSkipEntry is reported as done but not counted.
+*/
+func (j *job) entry(destination string, size int64, write func() error) error {
+	name := j.name(destination)
+	if err := j.checkEntries(name); err != nil {
+		return err
+	}
+
+	j.progress.EntryStart(j.res.Archive, name, size)
+	err := write()
+	if err == errSkipEntry {
+		j.progress.EntryDone(j.res.Archive, name, nil)
+		return nil
+	}
+	j.progress.EntryDone(j.res.Archive, name, err)
+	if err != nil {
+		return err
+	}
+	j.res.Entries++
+	return nil
+}
+
+/*
+	extractArchive extracts filename using x outside of any wider
+	extraction, as used by the Extract method of each format.
+*/
+func extractArchive(x archiveExtractor, filename, destination string, p ProgressReporter) error {
+	j := newJob(context.Background(), &Options{Progress: p}, &ArchiveResult{})
+	return j.run(x, filename, destination)
+}
+
+/*
+	ctxReader is an io.Reader that returns the error of its context once
+	the context is done, which stops any io.Copy reading from it.
+*/
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+/*
+	jobReader reads the data of the entry name for a job, stopping once
+	the job is cancelled or a limit is reached, and reporting the bytes
+	read as they are written. When hash is set, the data is also added
+	to it.
+*/
+type jobReader struct {
+	j       *job
+	r       io.Reader
+	name    string
+	written int64
+	hash    hash.Hash
+}
+
+func (jr *jobReader) Read(b []byte) (int, error) {
+	if err := jr.j.err(); err != nil {
+		return 0, err
+	}
+	n, err := jr.r.Read(b)
+	if n > 0 {
+		var lerr error
+		n, lerr = jr.j.allow(jr.name, jr.written, n)
+		if lerr != nil {
+			err = lerr
+		}
+		jr.written += int64(n)
+		if jr.hash != nil {
+			jr.hash.Write(b[:n])
+		}
+		jr.j.res.Bytes += int64(n)
+		jr.j.progress.BytesWritten(jr.j.res.Archive, int64(n))
+	}
+	return n, err
+}
+
+/*
+	sourceReader reports the bytes read from an archive to its job. It
+	also implements io.ReaderAt when the underlying reader does, which
+	is needed to read zip archives.
+*/
+type sourceReader struct {
+	j *job
+	r io.Reader
+}
+
+func (sr sourceReader) Read(b []byte) (int, error) {
+	n, err := sr.r.Read(b)
+	sr.j.read(int64(n))
+	return n, err
+}
+
+func (sr sourceReader) ReadAt(b []byte, off int64) (int, error) {
+	ra, ok := sr.r.(io.ReaderAt)
+	if !ok {
+		return 0, fmt.Errorf("input is not a ReaderAt")
+	}
+	n, err := ra.ReadAt(b, off)
+	sr.j.read(int64(n))
+	return n, err
+}
+
+/*
+	extractFrom extracts each entry read by next until the archive is
+	exhausted or the job is cancelled.
+*/
+func (j *job) extractFrom(next func() error) error {
+	for {
+		if err := j.err(); err != nil {
+			return err
+		}
+		err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err == errSkipEntry {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
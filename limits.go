@@ -0,0 +1,83 @@
+package extract
+
+import "sync/atomic"
+
+// ratioGrace is the output written before Limits.MaxRatio is enforced,
+// so that small files which compress well are not rejected.
+const ratioGrace = 1 << 20
+
+/*
+	Limits bounds the output of the extraction of a single archive, to
+	protect against decompression bombs. The sizes are checked against
+	the bytes written as the entries are decompressed, rather than the
+	sizes in the headers of the archive, which can lie. A limit of zero
+	is not enforced.
+
+	An extraction that reaches a limit stops with a LimitExceededError,
+	and the entry being written when it was reached is removed.
+*/
+type Limits struct {
+	/*
+		MaxBytes is the total uncompressed bytes written for the archive.
+		It also bounds the zip archives read from a stream, which are
+		spooled to a temporary file before they are extracted.
+	*/
+	MaxBytes int64
+
+	// MaxEntryBytes is the uncompressed bytes written for each entry.
+	MaxEntryBytes int64
+
+	// MaxEntries is the number of entries extracted from the archive.
+	MaxEntries int
+
+	/*
+		MaxRatio is the ratio of the bytes written to the compressed
+		bytes read from the archive. It is enforced once more than
+		1 MiB has been written.
+	*/
+	MaxRatio float64
+
+	// MaxDepth is the number of path components in the name of an entry.
+	MaxDepth int
+}
+
+/*
+	allow returns how many of the n bytes read for the entry name, of
+	which written bytes have already been written, may be written out.
+	An error is returned along with the count once a limit is reached.
+*/
+func (j *job) allow(name string, written int64, n int) (int, error) {
+	l := j.opts.Limits
+	if l.MaxEntryBytes > 0 && written+int64(n) > l.MaxEntryBytes {
+		return int(l.MaxEntryBytes - written), &LimitExceededError{Limit: "MaxEntryBytes", Filename: name}
+	}
+	if l.MaxBytes > 0 && j.res.Bytes+int64(n) > l.MaxBytes {
+		return int(l.MaxBytes - j.res.Bytes), &LimitExceededError{Limit: "MaxBytes", Filename: name}
+	}
+	if total := j.res.Bytes + int64(n); l.MaxRatio > 0 && total > ratioGrace {
+		if float64(total) > l.MaxRatio*float64(atomic.LoadInt64(&j.consumed)) {
+			return 0, &LimitExceededError{Limit: "MaxRatio", Filename: name}
+		}
+	}
+	return n, nil
+}
+
+// checkEntries returns an error if another entry would exceed MaxEntries.
+func (j *job) checkEntries(name string) error {
+	if max := j.opts.Limits.MaxEntries; max > 0 && j.res.Entries >= max {
+		return &LimitExceededError{Limit: "MaxEntries", Filename: name}
+	}
+	return nil
+}
+
+// checkDepth returns an error if the entry name is nested beyond MaxDepth.
+func (j *job) checkDepth(name string) error {
+	max := j.opts.Limits.MaxDepth
+	if max <= 0 || name == "." {
+		return nil
+	}
+	if len(splitPath(name)) > max {
+		return &LimitExceededError{Limit: "MaxDepth", Filename: name}
+	}
+	return nil
+}
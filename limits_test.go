@@ -0,0 +1,73 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func bombArchive(t *testing.T, entries int, size int64) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	zeros := make([]byte, size)
+	for i := 0; i < entries; i++ {
+		err := tw.WriteHeader(&tar.Header{Name: "a/b/c/" + string(rune('a'+i)), Typeflag: tar.TypeReg, Mode: 0644, Size: size})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(zeros)
+	}
+	tw.Close()
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestLimits(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_limits")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	for i, tc := range []struct {
+		limits Limits
+		expect string
+	}{
+		{limits: Limits{}},
+		{limits: Limits{MaxBytes: 3 << 20, MaxEntryBytes: 1 << 20, MaxEntries: 3, MaxDepth: 4, MaxRatio: 2000}},
+		{limits: Limits{MaxBytes: 2<<20 + 10}, expect: "MaxBytes"},
+		{limits: Limits{MaxEntryBytes: 1<<20 - 1}, expect: "MaxEntryBytes"},
+		{limits: Limits{MaxEntries: 2}, expect: "MaxEntries"},
+		{limits: Limits{MaxDepth: 3}, expect: "MaxDepth"},
+		{limits: Limits{MaxRatio: 10}, expect: "MaxRatio"},
+	} {
+		dest := filepath.Join(parent, string(rune('a'+i)))
+		in := bytes.NewReader(bombArchive(t, 3, 1<<20))
+		res, err := ExtractStreamContext(context.Background(), in, dest, Options{Limits: tc.limits})
+		if tc.expect == "" {
+			if err != nil {
+				t.Errorf("[%d] expected no error but got %v", i, err)
+			}
+			continue
+		}
+
+		var got *LimitExceededError
+		if !errors.As(err, &got) {
+			t.Errorf("[%d] expected LimitExceededError but got %v", i, err)
+			continue
+		}
+		if got.Limit != tc.expect {
+			t.Errorf("[%d] expected %s to be exceeded but got %v", i, tc.expect, err)
+		}
+		if tc.limits.MaxBytes > 0 && res.Bytes > tc.limits.MaxBytes {
+			t.Errorf("[%d] wrote %d bytes, more than the limit of %d", i, res.Bytes, tc.limits.MaxBytes)
+		}
+	}
+}
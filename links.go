@@ -0,0 +1,118 @@
+package extract
+
+import "path/filepath"
+
+/*
+	LinkPolicy controls which symlink and hardlink targets are created
+	as an archive is extracted. Links that the policy does not allow
+	are reported with an IllegalLinkError.
+*/
+type LinkPolicy int
+
+const (
+	/*
+		Confine only creates links whose targets resolve to a path
+		within the destination. It is the default policy.
+	*/
+	Confine LinkPolicy = iota
+
+	/*
+		AllowAbsolute also creates symlinks to absolute targets, while
+		relative targets must still stay within the destination.
+	*/
+	AllowAbsolute
+
+	/*
+		AllowEscaping creates every link as it is found in the archive,
+		including those that point outside of the destination.
+	*/
+	AllowEscaping
+
+	// Deny refuses to create any links.
+	Deny
+)
+
+func (p LinkPolicy) String() string {
+	switch p {
+	case Confine:
+		return "confine"
+	case AllowAbsolute:
+		return "allow-absolute"
+	case AllowEscaping:
+		return "allow-escaping"
+	case Deny:
+		return "deny"
+	}
+	return "unknown"
+}
+
+/*
+	checkSymlink confirms that policy allows a symlink to link to be
+	created at destination, a path within root. Relative targets are
+	resolved from the directory holding the link, following any links
+	already extracted.
+*/
+func checkSymlink(root, destination, link string, policy LinkPolicy) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	illegal := &IllegalLinkError{Filename: relName(root, destination), Link: link}
+
+	switch policy {
+	case AllowEscaping:
+		return nil
+	case Deny:
+		return illegal
+	}
+
+	if filepath.IsAbs(link) {
+		if policy == AllowAbsolute {
+			return nil
+		}
+		if _, ok := within(root, link); ok {
+			return nil
+		}
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			if _, ok := within(resolved, link); ok {
+				return nil
+			}
+		}
+		return illegal
+	}
+
+	// The target is joined without cleaning it, so that any ".." is
+	// applied after the links before it have been followed.
+	dir := filepath.Dir(illegal.Filename)
+	_, err = SecurePath(root, dir+string(filepath.Separator)+link)
+	if IsIllegalPathError(err) {
+		return illegal
+	}
+	return err
+}
+
+/*
+	hardlinkTarget returns the path that a hardlink to link should be
+	made from. Hardlink targets name another entry of the archive, so
+	are resolved relative to root rather than the working directory.
+*/
+func hardlinkTarget(root, destination, link string, policy LinkPolicy) (string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	illegal := &IllegalLinkError{Filename: relName(root, destination), Link: link}
+
+	switch policy {
+	case AllowEscaping:
+		return filepath.Join(root, link), nil
+	case Deny:
+		return "", illegal
+	}
+
+	target, err := SecurePath(root, link)
+	if IsIllegalPathError(err) {
+		return "", illegal
+	}
+	return target, err
+}
@@ -0,0 +1,153 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func linkArchive(t *testing.T, headers ...*tar.Header) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "dir/file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4})
+	tw.Write([]byte("data"))
+	for _, h := range headers {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	return &buf
+}
+
+func TestLinkPolicy(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_links")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	symlink := func(link string) *tar.Header {
+		return &tar.Header{Name: "dir/link", Typeflag: tar.TypeSymlink, Linkname: link}
+	}
+	hardlink := func(link string) *tar.Header {
+		return &tar.Header{Name: "dir/hard", Typeflag: tar.TypeLink, Linkname: link}
+	}
+
+	for i, tc := range []struct {
+		header *tar.Header
+		policy LinkPolicy
+		legal  bool
+	}{
+		{header: symlink("file"), policy: Confine, legal: true},
+		{header: symlink("../dir/file"), policy: Confine, legal: true},
+		{header: symlink("../../etc/passwd"), policy: Confine},
+		{header: symlink("/etc/passwd"), policy: Confine},
+		{header: symlink("/etc/passwd"), policy: AllowAbsolute, legal: true},
+		{header: symlink("../../etc/passwd"), policy: AllowAbsolute},
+		{header: symlink("../../etc/passwd"), policy: AllowEscaping, legal: true},
+		{header: symlink("file"), policy: Deny},
+		{header: hardlink("dir/file"), policy: Confine, legal: true},
+		{header: hardlink("../etc/passwd"), policy: Confine},
+		{header: hardlink("dir/file"), policy: Deny},
+	} {
+		dest := filepath.Join(parent, "out", string(rune('a'+i)))
+		err := ExtractStream(linkArchive(t, tc.header), dest, Options{Links: tc.policy})
+		if tc.legal && err != nil {
+			t.Errorf("[%d] %s -> %s (%v): expected no error but got %v", i, tc.header.Name, tc.header.Linkname, tc.policy, err)
+		}
+		if !tc.legal && !IsIllegalLinkError(err) {
+			t.Errorf("[%d] %s -> %s (%v): expected IllegalLinkError but got %v", i, tc.header.Name, tc.header.Linkname, tc.policy, err)
+		}
+		if !tc.legal && FileExists(filepath.Join(dest, tc.header.Name)) {
+			t.Errorf("[%d] %s: link was created", i, tc.header.Name)
+		}
+	}
+}
+
+func TestHardlinkRelativeToDestination(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_hardlink")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	dest := filepath.Join(parent, "out")
+	in := linkArchive(t, &tar.Header{Name: "hard", Typeflag: tar.TypeLink, Linkname: "dir/file"})
+	if err := ExtractStream(in, dest, Options{TopLevel: Never}); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(dest, "hard"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "data" {
+		t.Errorf("expected hardlink to hold %q but got %q", "data", got)
+	}
+}
+
+func TestDirOverSymlink(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_links")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+	outside, err := ioutil.TempDir("", "extract_outside")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(outside)
+
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(outside, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(outside, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dest, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dest, "real"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real", filepath.Join(dest, "inner")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	entry := time.Date(2001, 9, 9, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"link/", "inner/"} {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0777, ModTime: entry})
+	}
+	tw.WriteHeader(&tar.Header{Name: "inner/file", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.Close()
+
+	opts := Options{TopLevel: Never, PreservePermissions: true}
+	if _, err := ExtractStreamContext(context.Background(), &buf, dest, opts); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	fi, err := os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(old) || fi.Mode().Perm() != 0700 {
+		t.Errorf("expected the directory outside to be left but got %v at %v", fi.Mode(), fi.ModTime())
+	}
+	if fi, err := os.Lstat(filepath.Join(dest, "link")); err != nil || !fi.IsDir() {
+		t.Errorf("expected the outside symlink to be replaced by a directory")
+	}
+	if fi, err := os.Lstat(filepath.Join(dest, "inner")); err != nil || !IsSymlink(fi) {
+		t.Errorf("expected the symlink within the destination to be kept")
+	}
+	if !FileExists(filepath.Join(dest, "real", "file")) {
+		t.Errorf("expected the entry to be written through the symlink within the destination")
+	}
+}
@@ -0,0 +1,274 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zip"
+	"github.com/klauspost/pgzip"
+	"github.com/nwaples/rardecode"
+)
+
+/*
+	Entry describes a single entry of an archive, as returned by List.
+*/
+type Entry struct {
+	Name string `json:"name"`
+
+	// Size is the size of the entry once extracted, or -1 when the
+	// archive does not record it.
+	Size int64 `json:"size"`
+
+	/*
+		CompressedSize is the size of the entry within the archive, or
+		-1 when the archive does not record it, as for the entries of a
+		compressed tar.
+	*/
+	CompressedSize int64 `json:"compressed_size"`
+
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+
+	// Link is the target of a symlink or hardlink.
+	Link string `json:"link,omitempty"`
+
+	/*
+		Method names how the entry is compressed, such as "deflate" or
+		"store". It is empty when the format does not record it.
+	*/
+	Method string `json:"method,omitempty"`
+
+	// CRC32 is the checksum recorded for the entry, or zero when the
+	// format records none.
+	CRC32 uint32 `json:"crc32,omitempty"`
+}
+
+/*
+	List returns the entries of the archive at path, in the order they
+	are stored, without extracting them. Zip archives are listed from
+	the central directory, while the entries of other archives are read
+	through in turn. A compressed file that holds a single file rather
+	than an archive is listed as a single entry, named as it would be
+	extracted.
+*/
+func List(path string) ([]Entry, error) {
+	iface, err := GetFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f := iface.(type) {
+	case *Zip:
+		return listZip(path, f)
+	case *TarGz:
+		f.wrapReader()
+		return listTar(path, f.Tar, false)
+	case *TarBz2:
+		f.wrapReader()
+		return listTar(path, f.Tar, false)
+	case *TarXz:
+		f.wrapReader()
+		return listTar(path, f.Tar, false)
+	case *Tar:
+		return listTar(path, f, true)
+	case *Rar:
+		return listRar(path, f)
+	case *Gz:
+		return listGz(path)
+	case *Bz2:
+		return listBz2(path)
+	default:
+		return nil, fmt.Errorf("%s: %T cannot be listed", path, f)
+	}
+}
+
+// listZip lists the entries of a zip archive from its central directory.
+func listZip(filename string, z *Zip) ([]Entry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	fInfo, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error getting file info for %s: %v", filename, err)
+	}
+	err = z.Open(f, fInfo.Size())
+	if err != nil {
+		return nil, fmt.Errorf("error opening archive for reading: %v", err)
+	}
+	defer z.Close()
+
+	var entries []Entry
+	for {
+		file, err := z.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fh := file.Header.(zip.FileHeader)
+		e := Entry{
+			Name:           fh.Name,
+			Size:           int64(fh.UncompressedSize64),
+			CompressedSize: int64(fh.CompressedSize64),
+			Mode:           file.Mode(),
+			ModTime:        fh.Modified,
+			Method:         ZipCompressionMethod(fh.Method).String(),
+			CRC32:          fh.CRC32,
+		}
+		if IsSymlink(file.FileInfo) {
+			// The target of a symlink is held as its content.
+			var buf bytes.Buffer
+			_, err = io.Copy(&buf, file)
+			if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%s: error reading symlink target: %v", fh.Name, err)
+			}
+			e.Link = strings.TrimSpace(buf.String())
+		}
+		file.Close()
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+/*
+	listTar lists the entries of a tar archive, reading through it. The
+	entries of a plain tar are stored as they are, while the size of
+	each within a tar that is compressed as a whole is not known.
+*/
+func listTar(filename string, t *Tar, stored bool) ([]Entry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("problems opening the tar archive %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	err = t.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+
+	var entries []Entry
+	for {
+		file, err := t.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("issue scanning tar file listings: %v", err)
+		}
+		h := file.Header.(*tar.Header)
+		e := Entry{
+			Name:           h.Name,
+			Size:           h.Size,
+			CompressedSize: -1,
+			Mode:           file.Mode(),
+			ModTime:        h.ModTime,
+			Link:           h.Linkname,
+		}
+		if stored {
+			e.CompressedSize, e.Method = h.Size, "store"
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+/*
+	listRar lists the entries of a rar archive, reading through it. The
+	methods and checksums of rar entries are not available.
+*/
+func listRar(filename string, rar *Rar) ([]Entry, error) {
+	err := rar.OpenRarFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open rar file for reading: %v", err)
+	}
+	defer rar.Close()
+
+	var entries []Entry
+	for {
+		file, err := rar.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("issue scanning rar file listings: %v", err)
+		}
+		fh := file.Header.(*rardecode.FileHeader)
+		e := Entry{
+			Name:           fh.Name,
+			Size:           fh.UnPackedSize,
+			CompressedSize: fh.PackedSize,
+			Mode:           fh.Mode(),
+			ModTime:        fh.ModificationTime,
+		}
+		if fh.UnKnownSize {
+			e.Size = -1
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+/*
+	listGz lists the single file of a gzip file. Its size and checksum
+	are taken from the trailer, which records the size modulo 4 GiB.
+*/
+func listGz(filename string) ([]Entry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("problem opening %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	r, err := pgzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	r.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error getting file info for %s: %v", filename, err)
+	}
+	trailer := make([]byte, 8)
+	if _, err := f.ReadAt(trailer, fi.Size()-8); err != nil {
+		return nil, fmt.Errorf("%s: error reading gzip trailer: %v", filename, err)
+	}
+
+	return []Entry{{
+		Name:           GetFileName(filename),
+		Size:           int64(binary.LittleEndian.Uint32(trailer[4:])),
+		CompressedSize: fi.Size(),
+		Mode:           streamInfo{}.Mode(),
+		ModTime:        r.ModTime,
+		Method:         "deflate",
+		CRC32:          binary.LittleEndian.Uint32(trailer),
+	}}, nil
+}
+
+// listBz2 lists the single file of a bzip2 file, whose size is not recorded.
+func listBz2(filename string) ([]Entry, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, fmt.Errorf("problem opening %s: %v", filename, err)
+	}
+	return []Entry{{
+		Name:           GetFileName(filename),
+		Size:           -1,
+		CompressedSize: fi.Size(),
+		Mode:           streamInfo{}.Mode(),
+		Method:         "bzip2",
+	}}, nil
+}
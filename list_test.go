@@ -0,0 +1,109 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		file       string
+		entries    int
+		compressed bool
+	}{
+		{file: "testdata/test.tar", entries: 24},
+		{file: "testdata/test.tar.gz", entries: 24, compressed: true},
+		{file: "testdata/test.tgz", entries: 24, compressed: true},
+		{file: "testdata/test.zip", entries: 24},
+		{file: "testdata/test.rar", entries: 24},
+	} {
+		entries, err := List(tc.file)
+		if err != nil {
+			t.Fatalf("%s: expected no error but got %v", tc.file, err)
+		}
+		if len(entries) != tc.entries {
+			t.Errorf("%s: expected %d entries but got %d", tc.file, tc.entries, len(entries))
+		}
+
+		var found bool
+		for _, e := range entries {
+			if e.Name != "test/80nj" {
+				continue
+			}
+			found = true
+			if e.Size != 3511 || !e.Mode.IsRegular() || e.ModTime.IsZero() {
+				t.Errorf("%s: unexpected entry %+v", tc.file, e)
+			}
+			if tc.compressed != (e.CompressedSize < 0) {
+				t.Errorf("%s: unexpected compressed size %d", tc.file, e.CompressedSize)
+			}
+		}
+		if !found {
+			t.Errorf("%s: expected test/80nj to be listed", tc.file)
+		}
+	}
+
+	entries, err := List("testdata/test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Mode.IsRegular() && e.Method != "store" && e.Method != "deflate" {
+			t.Errorf("%s: unexpected method %q", e.Name, e.Method)
+		}
+	}
+
+	entries, err = List("testdata/test.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "test" {
+		t.Fatalf("expected the single entry test but got %+v", entries)
+	}
+	if e := entries[0]; e.Size != int64(len(data)) || e.CRC32 != crc32.ChecksumIEEE(data) {
+		t.Errorf("expected size %d and checksum %08x but got %+v", len(data), crc32.ChecksumIEEE(data), e)
+	}
+}
+
+func TestListLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract_list")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.WriteHeader(&tar.Header{Name: "sym", Typeflag: tar.TypeSymlink, Linkname: "file"})
+	tw.WriteHeader(&tar.Header{Name: "hard", Typeflag: tar.TypeLink, Linkname: "file"})
+	tw.Close()
+	archive := filepath.Join(dir, "links.tar")
+	if err := ioutil.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := List(archive)
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries but got %d", len(entries))
+	}
+	if e := entries[1]; e.Link != "file" || e.Mode&os.ModeSymlink == 0 {
+		t.Errorf("expected a symlink to file but got %+v", e)
+	}
+	if e := entries[2]; e.Link != "file" {
+		t.Errorf("expected a hardlink to file but got %+v", e)
+	}
+}
@@ -0,0 +1,430 @@
+package extract
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zip"
+	"github.com/klauspost/pgzip"
+	"github.com/nwaples/rardecode"
+)
+
+/*
+	Checksum is the hash of the data of a file extracted from an
+	archive. Name is relative to the destination of the archive, and
+	uses "/" as the separator.
+*/
+type Checksum struct {
+	Name string `json:"name"`
+	Sum  string `json:"sum"`
+}
+
+/*
+	Manifest lists the checksums of the files extracted from an
+	archive, sorted by name, as computed with Hash.
+*/
+type Manifest struct {
+	Hash  crypto.Hash
+	Files []Checksum
+}
+
+// ManifestFormat is the format a Manifest is written in.
+type ManifestFormat int
+
+const (
+	/*
+		SumManifest writes a line for each file in the format of
+		sha256sum and similar tools, so that it can be checked with
+		"sha256sum -c" from the destination.
+	*/
+	SumManifest ManifestFormat = iota
+
+	// JSONManifest writes the name of the hash and the files as JSON.
+	JSONManifest
+)
+
+var hashNames = map[crypto.Hash]string{
+	crypto.MD5:    "md5",
+	crypto.SHA1:   "sha1",
+	crypto.SHA224: "sha224",
+	crypto.SHA256: "sha256",
+	crypto.SHA384: "sha384",
+	crypto.SHA512: "sha512",
+}
+
+/*
+	ParseHash returns the hash named by s, one of "md5", "sha1",
+	"sha224", "sha256", "sha384" or "sha512".
+*/
+func ParseHash(s string) (crypto.Hash, error) {
+	for h, name := range hashNames {
+		if name == strings.ToLower(s) {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown hash: %s", s)
+}
+
+// jsonManifest is a Manifest as it is written as JSON.
+type jsonManifest struct {
+	Algorithm string     `json:"algorithm"`
+	Files     []Checksum `json:"files"`
+}
+
+/*
+	Write writes the manifest to w in format. Names holding a newline or
+	backslash are escaped in the sum format as sha256sum does.
+*/
+func (m *Manifest) Write(w io.Writer, format ManifestFormat) error {
+	switch format {
+	case SumManifest:
+		bw := bufio.NewWriter(w)
+		for _, c := range m.Files {
+			name, escaped := escapeSumName(c.Name)
+			if escaped {
+				bw.WriteString(`\`)
+			}
+			fmt.Fprintf(bw, "%s  %s\n", c.Sum, name)
+		}
+		return bw.Flush()
+	case JSONManifest:
+		files := m.Files
+		if files == nil {
+			files = []Checksum{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(jsonManifest{Algorithm: hashNames[m.Hash], Files: files})
+	}
+	return fmt.Errorf("unknown manifest format: %d", format)
+}
+
+/*
+	ReadManifest reads a manifest written by Write in either format. The
+	hash of a manifest in the sum format is found from the length of its
+	sums.
+*/
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var jm jsonManifest
+		if err := json.Unmarshal(data, &jm); err != nil {
+			return nil, fmt.Errorf("reading manifest: %v", err)
+		}
+		h, err := ParseHash(jm.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest: %v", err)
+		}
+		return &Manifest{Hash: h, Files: jm.Files}, nil
+	}
+
+	m := &Manifest{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(line, `\`)
+		if escaped {
+			line = line[1:]
+		}
+		// The name follows the sum and a space, and then a space or,
+		// for files read in binary mode, an asterisk.
+		sp := strings.IndexByte(line, ' ')
+		if sp < 0 || sp+2 > len(line) {
+			return nil, fmt.Errorf("reading manifest: line %d: invalid line", i+1)
+		}
+		sum, name := line[:sp], line[sp+2:]
+		if escaped {
+			name = unescapeSumName(name)
+		}
+		h, ok := hashBySize(len(sum) / 2)
+		if !ok || (m.Hash != 0 && h != m.Hash) {
+			return nil, fmt.Errorf("reading manifest: line %d: unexpected sum %s", i+1, sum)
+		}
+		m.Hash = h
+		m.Files = append(m.Files, Checksum{Name: name, Sum: strings.ToLower(sum)})
+	}
+	if m.Hash == 0 {
+		return nil, fmt.Errorf("reading manifest: no files listed")
+	}
+	return m, nil
+}
+
+// hashBySize returns the hash whose sums are size bytes long.
+func hashBySize(size int) (crypto.Hash, bool) {
+	for _, h := range []crypto.Hash{crypto.MD5, crypto.SHA1, crypto.SHA224, crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		if h.Size() == size {
+			return h, true
+		}
+	}
+	return 0, false
+}
+
+// escapeSumName escapes name as sha256sum does, reporting whether it did.
+func escapeSumName(name string) (string, bool) {
+	if !strings.ContainsAny(name, "\\\n") {
+		return name, false
+	}
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(name), true
+}
+
+func unescapeSumName(name string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(name)
+}
+
+/*
+	Verify checks the files listed in the manifest against those within
+	dir, returning an error for each file that is missing or does not
+	match. Files within dir that are not listed are ignored.
+*/
+func (m *Manifest) Verify(dir string) ([]*ChecksumError, error) {
+	if !m.Hash.Available() {
+		return nil, fmt.Errorf("hash %v is not available", m.Hash)
+	}
+
+	var failures []*ChecksumError
+	for _, c := range m.Files {
+		sum, err := m.sumFile(dir, c.Name)
+		if err != nil {
+			failures = append(failures, &ChecksumError{Filename: c.Name, Expected: c.Sum, Err: err})
+			continue
+		}
+		if sum != c.Sum {
+			failures = append(failures, &ChecksumError{Filename: c.Name, Expected: c.Sum, Actual: sum})
+		}
+	}
+	return failures, nil
+}
+
+// sumFile returns the sum of the file name within dir.
+func (m *Manifest) sumFile(dir, name string) (string, error) {
+	p, err := SecurePath(dir, name)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := m.Hash.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+/*
+	VerifyArchive checks the files listed in the manifest against the
+	entries of the archive at path, without extracting it. The names in
+	the manifest are matched against those of the entries as they are
+	stored, so those of an archive extracted with StripComponents or
+	Rename will not be found.
+*/
+func (m *Manifest) VerifyArchive(path string) ([]*ChecksumError, error) {
+	if !m.Hash.Available() {
+		return nil, fmt.Errorf("hash %v is not available", m.Hash)
+	}
+
+	sums := make(map[string]string)
+	err := readEntries(path, func(name string, f File) error {
+		if !f.Mode().IsRegular() {
+			return nil
+		}
+		h := m.Hash.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		sums[cleanName(name)] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []*ChecksumError
+	for _, c := range m.Files {
+		sum, ok := sums[cleanName(c.Name)]
+		switch {
+		case !ok:
+			failures = append(failures, &ChecksumError{Filename: c.Name, Expected: c.Sum, Err: os.ErrNotExist})
+		case sum != c.Sum:
+			failures = append(failures, &ChecksumError{Filename: c.Name, Expected: c.Sum, Actual: sum})
+		}
+	}
+	return failures, nil
+}
+
+// cleanName returns the name of an entry as it is extracted.
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.Replace(name, `\`, "/", -1)), "/")
+}
+
+/*
+	readEntries calls fn with each entry of the archive at path in turn,
+	with the data of the entry ready to be read from f.
+*/
+func readEntries(path string, fn func(name string, f File) error) error {
+	iface, err := GetFormat(path)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("problem opening %s: %v", path, err)
+	}
+	defer in.Close()
+
+	var read func() (File, error)
+	switch a := iface.(type) {
+	case *Zip:
+		fi, err := in.Stat()
+		if err != nil {
+			return err
+		}
+		if err := a.Open(in, fi.Size()); err != nil {
+			return fmt.Errorf("error opening archive for reading: %v", err)
+		}
+		defer a.Close()
+		read = a.Read
+	case *TarGz:
+		if err := a.Open(in); err != nil {
+			return err
+		}
+		defer a.Close()
+		read = a.Read
+	case *TarBz2:
+		if err := a.Open(in); err != nil {
+			return err
+		}
+		defer a.Close()
+		read = a.Read
+	case *TarXz:
+		if err := a.Open(in); err != nil {
+			return err
+		}
+		defer a.Close()
+		read = a.Read
+	case *Tar:
+		if err := a.Open(in); err != nil {
+			return err
+		}
+		defer a.Close()
+		read = a.Read
+	case *Rar:
+		if err := a.OpenRarFile(path); err != nil {
+			return fmt.Errorf("unable to open rar file for reading: %v", err)
+		}
+		defer a.Close()
+		read = a.Read
+	case *Gz:
+		r, err := pgzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return fn(GetFileName(path), File{FileInfo: streamInfo{GetFileName(path), r.ModTime}, ReadCloser: r})
+	case *Bz2:
+		r, err := bzip2.NewReader(in, nil)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return fn(GetFileName(path), File{FileInfo: streamInfo{name: GetFileName(path)}, ReadCloser: r})
+	default:
+		return fmt.Errorf("%s: %T cannot be read", path, a)
+	}
+
+	for {
+		f, err := read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(entryName(f), f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// entryName returns the full name of the entry f, as it is stored.
+func entryName(f File) string {
+	switch h := f.Header.(type) {
+	case zip.FileHeader:
+		return h.Name
+	case *tar.Header:
+		return h.Name
+	case *rardecode.FileHeader:
+		return h.Name
+	}
+	return f.Name()
+}
+
+/*
+	checksum records the sum in h of the file written to destination,
+	replacing that of any earlier entry written there.
+*/
+func (j *job) checksum(destination string, h hash.Hash) {
+	if j.sums == nil {
+		j.sums = make(map[string]string)
+	}
+	j.sums[j.name(destination)] = hex.EncodeToString(h.Sum(nil))
+}
+
+/*
+	moveChecksum moves the sum of the file at from to that at to, once
+	it is moved by the overwrite policy, or drops it when to is empty.
+*/
+func (j *job) moveChecksum(from, to string) {
+	name := j.name(from)
+	sum, ok := j.sums[name]
+	if !ok {
+		return
+	}
+	delete(j.sums, name)
+	if to != "" {
+		j.sums[j.name(to)] = sum
+	}
+}
+
+// finishManifest sets the manifest of the result from the sums recorded.
+func (j *job) finishManifest() {
+	if j.opts.Checksum == 0 {
+		return
+	}
+	m := &Manifest{Hash: j.opts.Checksum}
+	for name, sum := range j.sums {
+		m.Files = append(m.Files, Checksum{Name: name, Sum: sum})
+	}
+	sort.Slice(m.Files, func(a, b int) bool {
+		return m.Files[a].Name < m.Files[b].Name
+	})
+	j.res.Manifest = m
+}
@@ -0,0 +1,139 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtractChecksum(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	want := hex.EncodeToString(sum[:])
+
+	for i, file := range []string{"testdata/test.tar.gz", "testdata/test.zip", "testdata/test.rar"} {
+		dest, err := ioutil.TempDir("", "extract_manifest")
+		if err != nil {
+			t.Fatalf("Error creating temporary dir")
+		}
+		defer os.RemoveAll(dest)
+
+		res, err := ExtractContext(context.Background(), []string{file}, Options{Destination: dest, Checksum: crypto.SHA256})
+		if err != nil {
+			t.Fatalf("[%d] %s: expected no error but got %v", i, file, err)
+		}
+		m := res.Archives[0].Manifest
+		if m == nil || len(m.Files) != 18 {
+			t.Fatalf("[%d] %s: expected a manifest of 18 files but got %v", i, file, m)
+		}
+		failures, err := m.Verify(dest)
+		if err != nil || len(failures) != 0 {
+			t.Errorf("[%d] %s: expected the tree to verify but got %v (%v)", i, file, failures, err)
+		}
+	}
+
+	dest, err := ioutil.TempDir("", "extract_manifest")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+	res, err := ExtractContext(context.Background(), []string{"testdata/test.gz"}, Options{Destination: dest, Checksum: crypto.SHA256})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	expect := []Checksum{{Name: "test", Sum: want}}
+	if m := res.Archives[0].Manifest; !reflect.DeepEqual(m.Files, expect) {
+		t.Errorf("expected %v but got %v", expect, m.Files)
+	}
+}
+
+func TestManifestReadWrite(t *testing.T) {
+	m := &Manifest{Hash: crypto.MD5, Files: []Checksum{
+		{Name: "a/b", Sum: "d41d8cd98f00b204e9800998ecf8427e"},
+		{Name: "back\\slash\nnewline", Sum: "0cc175b9c0f1b6a831c399e269772661"},
+	}}
+	for i, format := range []ManifestFormat{SumManifest, JSONManifest} {
+		var buf bytes.Buffer
+		if err := m.Write(&buf, format); err != nil {
+			t.Fatalf("[%d] expected no error but got %v", i, err)
+		}
+		got, err := ReadManifest(&buf)
+		if err != nil {
+			t.Fatalf("[%d] expected no error but got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("[%d] expected %v but got %v", i, m, got)
+		}
+	}
+
+	// Lines written by sha256sum in binary mode.
+	got, err := ReadManifest(bytes.NewBufferString("E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855 *empty\n"))
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if got.Hash != crypto.SHA256 || got.Files[0].Name != "empty" {
+		t.Errorf("expected a SHA-256 manifest of empty but got %v", got)
+	}
+
+	for i, in := range []string{"", "nosum\n", "abc  file\n", "d41d8cd98f00b204e9800998ecf8427e  a\nda39a3ee5e6b4b0d3255bfef95601890afd80709  b\n"} {
+		if _, err := ReadManifest(bytes.NewBufferString(in)); err == nil {
+			t.Errorf("[%d] expected error but got nil", i)
+		}
+	}
+}
+
+func TestManifestVerify(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_manifest")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	res, err := ExtractContext(context.Background(), []string{"testdata/test.tar"}, Options{Destination: dest, Checksum: crypto.SHA256})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	m := res.Archives[0].Manifest
+
+	for _, file := range []string{"testdata/test.tar", "testdata/test.tar.gz", "testdata/test.zip", "testdata/test.rar"} {
+		failures, err := m.VerifyArchive(file)
+		if err != nil || len(failures) != 0 {
+			t.Errorf("%s: expected the archive to verify but got %v (%v)", file, failures, err)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dest, "test", "80nj"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dest, "test", "xeso", "0yx5vo0")); err != nil {
+		t.Fatal(err)
+	}
+	failures, err := m.Verify(dest)
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures but got %v", failures)
+	}
+	for _, f := range failures {
+		if !IsChecksumError(f) {
+			t.Errorf("expected ChecksumError but got %T", f)
+		}
+	}
+	if failures[0].Filename != "test/80nj" || failures[0].Actual == "" {
+		t.Errorf("expected a mismatch for test/80nj but got %v", failures[0])
+	}
+	if failures[1].Filename != "test/xeso/0yx5vo0" || !os.IsNotExist(failures[1].Err) {
+		t.Errorf("expected test/xeso/0yx5vo0 to be missing but got %v", failures[1])
+	}
+}
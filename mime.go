@@ -1,23 +1,101 @@
 package extract
 
 import (
-	"sync"
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"sync/atomic"
 
 	"github.com/Galzzly/extract/v2/internal/magic"
+	"github.com/dsnet/compress/bzip2"
+	"github.com/ulikunitz/xz"
 )
 
-var mu = &sync.Mutex{}
+/*
+	Sniff holds the start of a file, read once so that every registered
+	format can be checked against it. Head is the first bytes of the
+	file. When the file is compressed with gzip, bzip2 or xz, Compression
+	is set to "gz", "bz2" or "xz", and Inner holds the first bytes of
+	the data within, taken from the first block alone.
+*/
+type Sniff struct {
+	Head        []byte
+	Compression string
+	Inner       []byte
 
-type MIME struct {
-	filetype string
-	detector magic.Detector
-	//children []*MIME
+	// limit is the read limit the sniff was taken with, which the
+	// detectors of internal/magic are given.
+	limit uint32
 }
 
-func newMime(filetype string, detector magic.Detector, children ...*MIME) *MIME {
-	m := &MIME{
-		filetype: filetype,
-		detector: detector,
+/*
+	SniffFile reads the start of the file at filename for detection,
+	opening it once.
+*/
+func SniffFile(filename string) (*Sniff, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
 	}
-	return m
+	defer f.Close()
+
+	return sniff(f)
+}
+
+/*
+	sniff reads the start of r, and of the data within it when it is
+	compressed. The decompressors are only given as much as they need to
+	fill the header, so at most the first block is decompressed.
+*/
+func sniff(r io.Reader) (*Sniff, error) {
+	l := atomic.LoadUint32(&readLimit)
+	br := bufio.NewReaderSize(r, int(l))
+	head, err := br.Peek(int(l))
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	// The peeked bytes are copied, as they are overwritten once the
+	// data within is read.
+	s := &Sniff{Head: append([]byte(nil), head...), limit: l}
+
+	var inner io.Reader
+	switch {
+	case magic.Gz(s.Head, l):
+		s.Compression = "gz"
+		// The single-threaded reader is used, as pgzip reads ahead.
+		if zr, err := gzip.NewReader(br); err == nil {
+			defer zr.Close()
+			inner = zr
+		}
+	case magic.Bz2(s.Head, l):
+		s.Compression = "bz2"
+		if zr, err := bzip2.NewReader(br, nil); err == nil {
+			defer zr.Close()
+			inner = zr
+		}
+	case magic.Xz(s.Head, l):
+		s.Compression = "xz"
+		if zr, err := xz.NewReader(br); err == nil {
+			inner = zr
+		}
+	}
+	if inner != nil {
+		// Inner is left empty when the data cannot be read, while the
+		// file is still reported as compressed.
+		s.Inner, _ = GetHeader(inner, l)
+	}
+	return s, nil
+}
+
+/*
+	detectFile sniffs the file at filename and reports whether detect
+	matches it, as used by the CheckFormat method of each format.
+*/
+func detectFile(filename string, detect FormatDetector) (bool, error) {
+	s, err := SniffFile(filename)
+	if err != nil {
+		return false, err
+	}
+	return detect(s), nil
 }
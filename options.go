@@ -0,0 +1,126 @@
+package extract
+
+import "crypto"
+
+// DefaultConcurrency is the number of archives extracted at once when
+// Options.Concurrency is not set.
+const DefaultConcurrency = 4
+
+/*
+	Options configures how archives are extracted.
+*/
+type Options struct {
+	/*
+		Destination is the directory the archives are extracted into.
+		ExtractStream takes its destination as an argument instead.
+	*/
+	Destination string
+
+	// Concurrency is the number of archives extracted at once.
+	Concurrency int
+
+	/*
+		Progress receives progress events as the archives are extracted.
+		When nil, no progress is reported.
+	*/
+	Progress ProgressReporter
+
+	/*
+		Name is the name of the archive being read. When extracting
+		from a stream that holds a single compressed file rather than
+		an archive, the output is named after Name with the compression
+		extensions removed.
+	*/
+	Name string
+
+	/*
+		Links controls which symlinks and hardlinks are created. The
+		default, Confine, only creates those whose targets are within
+		the destination.
+	*/
+	Links LinkPolicy
+
+	// Limits bounds the output of each archive. By default there are none.
+	Limits Limits
+
+	// Overwrite controls what happens to files that already exist.
+	Overwrite OverwritePolicy
+
+	/*
+		Atomic extracts each archive into a staging directory within
+		the destination, and only moves the files into place once the
+		whole archive has been extracted. An archive that fails, or is
+		cancelled, leaves the destination as it was.
+	*/
+	Atomic bool
+
+	/*
+		SameOwner restores the owner and group of each entry, along with
+		its extended attributes and POSIX ACLs. Owners can only be
+		restored when running as root; otherwise the entries are left
+		owned by the user, and a warning is recorded in the result.
+	*/
+	SameOwner bool
+
+	/*
+		PreservePermissions applies the mode of each entry as it is in
+		the archive, including the setuid, setgid and sticky bits. By
+		default the umask is applied and those bits are cleared.
+	*/
+	PreservePermissions bool
+
+	// Filter selects the entries that are extracted. By default, all are.
+	Filter Filter
+
+	/*
+		StripComponents removes that many leading elements from the name
+		of each entry before it is extracted, skipping the entries with
+		no more elements than that.
+	*/
+	StripComponents int
+
+	/*
+		Rename is called with the name of each entry, once any leading
+		elements are stripped, and returns the name that it is extracted
+		as, or "" to skip it. ParseTransform returns a Rename function
+		for a sed expression. The names are checked against the
+		destination after they are changed.
+
+		When either StripComponents or Rename is set, the Auto policy
+		does not wrap archives with several top level entries in a
+		directory.
+	*/
+	Rename func(name string) string
+
+	/*
+		TopLevel controls whether each archive is extracted into a
+		directory of its own. The default, Auto, does so for those with
+		more than one top level entry.
+	*/
+	TopLevel TopLevelPolicy
+
+	/*
+		Checksum is the hash computed over the data of each file as it
+		is written, such as crypto.SHA256. The sums are returned in the
+		Manifest of the result. By default none are computed.
+	*/
+	Checksum crypto.Hash
+
+	/*
+		DryRun detects the format and reads the entries of each archive,
+		applying the filters, path checks, top level and overwrite
+		policies, but writes nothing. The changes that would be made are
+		returned in the Plan of the result, with Entries and Skipped
+		counted as they would be. Entries whose paths or link targets
+		are not allowed are listed as rejected rather than stopping the
+		archive, while limits on the data written are not checked.
+	*/
+	DryRun bool
+
+	/*
+		StrictFormat fails archives whose extension names another format
+		than their content, rather than extracting them as the format
+		found with a warning.
+	*/
+	StrictFormat bool
+}
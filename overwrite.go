@@ -0,0 +1,110 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+	OverwritePolicy controls what happens when an entry is extracted to
+	a path that already exists. Directories are always merged, so the
+	policy applies to the files and links of the archive.
+*/
+type OverwritePolicy int
+
+const (
+	// Overwrite replaces the existing file. It is the default policy.
+	Overwrite OverwritePolicy = iota
+
+	// Skip leaves the existing file in place.
+	Skip
+
+	/*
+		KeepNewer replaces the existing file only when it was modified
+		before the entry.
+	*/
+	KeepNewer
+
+	/*
+		Rename writes the entry alongside the existing file, with the
+		first free numeric suffix added to its name, as in "file.txt.1".
+	*/
+	Rename
+
+	// Fail stops the extraction with an error wrapping os.ErrExist.
+	Fail
+)
+
+var overwritePolicies = map[OverwritePolicy]string{
+	Overwrite: "overwrite",
+	Skip:      "skip",
+	KeepNewer: "keep-newer",
+	Rename:    "rename",
+	Fail:      "fail",
+}
+
+func (p OverwritePolicy) String() string {
+	if s, ok := overwritePolicies[p]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+/*
+	ParseOverwritePolicy returns the OverwritePolicy named by s, as
+	returned by its String method.
+*/
+func ParseOverwritePolicy(s string) (OverwritePolicy, error) {
+	for p, name := range overwritePolicies {
+		if name == s {
+			return p, nil
+		}
+	}
+	return Overwrite, fmt.Errorf("unknown overwrite policy: %s", s)
+}
+
+/*
+	conflict applies the overwrite policy to an entry, modified at
+	modTime, that is to be written to destination. It returns the path
+	that the entry should be written to, or false if it is skipped. The
+	files of an atomic extraction are checked against the paths they
+	will be moved to, while those of a deferred archive are checked
+	once they are moved, by settle.
+*/
+func (j *job) conflict(destination string, modTime time.Time) (string, bool, error) {
+	target := j.target
+	if j.pending {
+		target = func(path string) string { return path }
+	}
+	fi, err := j.lstat(target(destination))
+	if err != nil || fi.IsDir() {
+		return destination, true, nil
+	}
+
+	switch j.opts.Overwrite {
+	case Skip:
+		return j.skip(destination)
+	case KeepNewer:
+		if !fi.ModTime().Before(modTime) {
+			return j.skip(destination)
+		}
+	case Rename:
+		for n := 1; ; n++ {
+			renamed := destination + "." + strconv.Itoa(n)
+			if _, err := j.lstat(target(renamed)); os.IsNotExist(err) {
+				return renamed, true, nil
+			}
+		}
+	case Fail:
+		return "", false, &os.PathError{Op: "extract", Path: j.name(destination), Err: os.ErrExist}
+	}
+	return destination, true, nil
+}
+
+// skip records the entry at destination as skipped.
+func (j *job) skip(destination string) (string, bool, error) {
+	j.res.Skipped = append(j.res.Skipped, j.name(destination))
+	return "", false, nil
+}
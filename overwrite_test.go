@@ -0,0 +1,87 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOverwritePolicy(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_overwrite")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	modTime := time.Now().Add(-time.Hour)
+	archive := func() *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		tw.WriteHeader(&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0644, Size: 3, ModTime: modTime})
+		tw.Write([]byte("new"))
+		tw.Close()
+		return &buf
+	}
+
+	for i, tc := range []struct {
+		policy   OverwritePolicy
+		existing time.Time
+		expect   string
+		renamed  bool
+		skipped  bool
+		fail     bool
+	}{
+		{policy: Overwrite, expect: "new"},
+		{policy: Skip, expect: "old", skipped: true},
+		{policy: KeepNewer, existing: modTime.Add(time.Minute), expect: "old", skipped: true},
+		{policy: KeepNewer, existing: modTime.Add(-time.Minute), expect: "new"},
+		{policy: Rename, expect: "old", renamed: true},
+		{policy: Fail, expect: "old", fail: true},
+	} {
+		dest := filepath.Join(parent, tc.policy.String()+string(rune('a'+i)))
+		file := filepath.Join(dest, "file")
+		if err := WriteFile(file, bytes.NewBufferString("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !tc.existing.IsZero() {
+			os.Chtimes(file, tc.existing, tc.existing)
+		}
+
+		res, err := ExtractStreamContext(context.Background(), archive(), dest, Options{Overwrite: tc.policy})
+		if tc.fail {
+			if !errors.Is(err, os.ErrExist) {
+				t.Errorf("[%d] %v: expected an error wrapping os.ErrExist but got %v", i, tc.policy, err)
+			}
+		} else if err != nil {
+			t.Errorf("[%d] %v: expected no error but got %v", i, tc.policy, err)
+		}
+
+		if got, _ := ioutil.ReadFile(file); string(got) != tc.expect {
+			t.Errorf("[%d] %v: expected file to hold %q but got %q", i, tc.policy, tc.expect, got)
+		}
+		if got, _ := ioutil.ReadFile(file + ".1"); tc.renamed && string(got) != "new" {
+			t.Errorf("[%d] %v: expected renamed file to hold %q but got %q", i, tc.policy, "new", got)
+		}
+		if skipped := len(res.Skipped) == 1; skipped != tc.skipped {
+			t.Errorf("[%d] %v: expected skipped to be %v but got %v", i, tc.policy, tc.skipped, res.Skipped)
+		}
+	}
+}
+
+func TestParseOverwritePolicy(t *testing.T) {
+	for _, p := range []OverwritePolicy{Overwrite, Skip, KeepNewer, Rename, Fail} {
+		got, err := ParseOverwritePolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("%v: expected %v but got %v (%v)", p, p, got, err)
+		}
+	}
+	if _, err := ParseOverwritePolicy("clobber"); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
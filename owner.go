@@ -0,0 +1,308 @@
+package extract
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zip"
+)
+
+// zipNewUnixExtraID is the id of the Info-ZIP extra field holding the
+// owner and group of an entry.
+const zipNewUnixExtraID = 0x7875
+
+// The prefixes of the PAX records holding extended attributes and ACLs.
+const (
+	paxXattrPrefix = "SCHILY.xattr."
+	paxACLAccess   = "SCHILY.acl.access"
+	paxACLDefault  = "SCHILY.acl.default"
+)
+
+// errUnsupported is returned where the platform cannot restore an attribute.
+var errUnsupported = errors.New("not supported on this platform")
+
+/*
+	mode returns the mode that the entry f is created with, which the
+	umask is applied to as it is created. Unless the permissions are
+	preserved, the setuid, setgid and sticky bits are cleared.
+*/
+func (j *job) mode(f File) os.FileMode {
+	if j.opts.PreservePermissions {
+		return f.Mode()
+	}
+	return f.Mode() &^ (os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+/*
+	restore sets the owner and extended attributes of the entry f
+	written to destination when the owner is kept, and then its mode,
+	without the umask, when the permissions are preserved, as changing
+	the owner clears the setuid, setgid and sticky bits. The mode is
+	never set through a symlink found at destination.
+*/
+func (j *job) restore(destination string, f File) error {
+	if j.opts.SameOwner {
+		j.restoreOwner(destination, f)
+	}
+
+	if j.opts.PreservePermissions && !IsSymlink(f) {
+		if fi, err := os.Lstat(destination); err != nil || IsSymlink(fi) {
+			return nil
+		}
+		if err := os.Chmod(destination, f.Mode()); err != nil {
+			return fmt.Errorf("%s: error setting permissions: %v", destination, err)
+		}
+	}
+	return nil
+}
+
+/*
+	restoreOwner sets the owner, extended attributes and ACLs of the
+	entry f written to destination, warning of any that cannot be set.
+*/
+func (j *job) restoreOwner(destination string, f File) {
+	name := j.name(destination)
+
+	if uid, gid, ok := entryOwner(f); ok {
+		if os.Geteuid() != 0 {
+			j.warnOnce("not running as root, so the owners of entries are not restored")
+		} else if err := os.Lchown(destination, uid, gid); err != nil {
+			j.warn("%s: unable to restore owner: %v", name, err)
+		}
+	}
+
+	for attr, value := range entryXattrs(f) {
+		if err := setXattr(destination, attr, []byte(value)); err != nil {
+			j.warn("%s: unable to restore extended attribute %s: %v", name, attr, err)
+		}
+	}
+	for attr, text := range entryACLs(f) {
+		acl, err := encodeACL(text)
+		if err == nil {
+			err = setXattr(destination, attr, acl)
+		}
+		if err != nil {
+			j.warn("%s: unable to restore ACL: %v", name, err)
+		}
+	}
+}
+
+/*
+	entryOwner returns the owner and group of f recorded by its archive.
+	Tar entries are looked up by the names of the owner and group where
+	they exist on this system, as tar does.
+*/
+func entryOwner(f File) (uid, gid int, ok bool) {
+	switch h := f.Header.(type) {
+	case *tar.Header:
+		uid, gid = h.Uid, h.Gid
+		if u, err := user.Lookup(h.Uname); h.Uname != "" && err == nil {
+			if id, err := strconv.Atoi(u.Uid); err == nil {
+				uid = id
+			}
+		}
+		if g, err := user.LookupGroup(h.Gname); h.Gname != "" && err == nil {
+			if id, err := strconv.Atoi(g.Gid); err == nil {
+				gid = id
+			}
+		}
+		return uid, gid, true
+	case zip.FileHeader:
+		return zipOwner(h.Extra)
+	}
+	return 0, 0, false
+}
+
+/*
+	zipOwner reads the owner and group from the Info-ZIP Unix extra
+	field: a version, then the size and value of each id.
+*/
+func zipOwner(extra []byte) (uid, gid int, ok bool) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		field := extra[:size]
+		extra = extra[size:]
+		if id != zipNewUnixExtraID || len(field) < 2 || field[0] != 1 {
+			continue
+		}
+
+		ids := make([]int, 0, 2)
+		for b := field[1:]; len(ids) < 2 && len(b) > 0; {
+			n := int(b[0])
+			if n > 8 || n+1 > len(b) {
+				break
+			}
+			var v uint64
+			for i := n; i > 0; i-- {
+				v = v<<8 | uint64(b[i])
+			}
+			ids = append(ids, int(v))
+			b = b[n+1:]
+		}
+		if len(ids) == 2 {
+			return ids[0], ids[1], true
+		}
+	}
+	return 0, 0, false
+}
+
+// entryXattrs returns the extended attributes recorded for f.
+func entryXattrs(f File) map[string]string {
+	h, ok := f.Header.(*tar.Header)
+	if !ok {
+		return nil
+	}
+	xattrs := make(map[string]string)
+	for k, v := range h.PAXRecords {
+		if strings.HasPrefix(k, paxXattrPrefix) {
+			xattrs[strings.TrimPrefix(k, paxXattrPrefix)] = v
+		}
+	}
+	return xattrs
+}
+
+/*
+	entryACLs returns the POSIX ACLs recorded for f in their text form,
+	keyed by the extended attribute that they are stored in.
+*/
+func entryACLs(f File) map[string]string {
+	h, ok := f.Header.(*tar.Header)
+	if !ok {
+		return nil
+	}
+	acls := make(map[string]string)
+	if v := h.PAXRecords[paxACLAccess]; v != "" {
+		acls["system.posix_acl_access"] = v
+	}
+	if v := h.PAXRecords[paxACLDefault]; v != "" {
+		acls["system.posix_acl_default"] = v
+	}
+	return acls
+}
+
+// The tags of the entries of a POSIX ACL, as stored in its attribute.
+const (
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclGroup    = 0x08
+	aclMask     = 0x10
+	aclOther    = 0x20
+
+	// aclNoID is the id of the entries that do not name one.
+	aclNoID = 0xffffffff
+)
+
+var aclTags = map[string]uint16{
+	"user":  aclUserObj,
+	"group": aclGroupObj,
+	"mask":  aclMask,
+	"other": aclOther,
+}
+
+/*
+	encodeACL converts an ACL from the text form recorded by tar, as in
+	"user::rw-,user:joe:r--:1000,group::r--,mask::r--,other::r--", to
+	the form stored in its extended attribute.
+*/
+func encodeACL(text string) ([]byte, error) {
+	type entry struct {
+		tag  uint16
+		perm uint16
+		id   uint32
+	}
+
+	var entries []entry
+	for _, s := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		parts := strings.Split(strings.TrimSpace(s), ":")
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("invalid ACL entry: %s", s)
+		}
+		tag, ok := aclTags[parts[0]]
+		if !ok {
+			return nil, fmt.Errorf("invalid ACL entry: %s", s)
+		}
+
+		e := entry{tag: tag, id: aclNoID}
+		for i, c := range "rwx" {
+			if strings.ContainsRune(parts[2], c) {
+				e.perm |= 4 >> i
+			}
+		}
+
+		if qualifier := parts[1]; qualifier != "" {
+			switch tag {
+			case aclUserObj:
+				e.tag = aclUser
+			case aclGroupObj:
+				e.tag = aclGroup
+			default:
+				return nil, fmt.Errorf("invalid ACL entry: %s", s)
+			}
+			id, err := aclID(e.tag, qualifier, parts[3:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid ACL entry: %s: %v", s, err)
+			}
+			e.id = id
+		}
+		entries = append(entries, e)
+	}
+
+	// The entries are stored in order of their tag, then their id.
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].tag != entries[b].tag {
+			return entries[a].tag < entries[b].tag
+		}
+		return entries[a].id < entries[b].id
+	})
+
+	buf := make([]byte, 4, 4+8*len(entries))
+	binary.LittleEndian.PutUint32(buf, 2)
+	for _, e := range entries {
+		var b [8]byte
+		binary.LittleEndian.PutUint16(b[:], e.tag)
+		binary.LittleEndian.PutUint16(b[2:], e.perm)
+		binary.LittleEndian.PutUint32(b[4:], e.id)
+		buf = append(buf, b[:]...)
+	}
+	return buf, nil
+}
+
+/*
+	aclID returns the id named by an ACL entry, preferring the name
+	where it exists on this system and then the numeric id recorded
+	after the permissions.
+*/
+func aclID(tag uint16, name string, rest []string) (uint32, error) {
+	var id string
+	if tag == aclUser {
+		if u, err := user.Lookup(name); err == nil {
+			id = u.Uid
+		}
+	} else if g, err := user.LookupGroup(name); err == nil {
+		id = g.Gid
+	}
+	if id == "" && len(rest) > 0 {
+		id = rest[0]
+	}
+	if id == "" {
+		id = name
+	}
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown user or group: %s", name)
+	}
+	return uint32(n), nil
+}
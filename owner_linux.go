@@ -0,0 +1,8 @@
+package extract
+
+import "golang.org/x/sys/unix"
+
+// setXattr sets the extended attribute of path, without following a symlink.
+func setXattr(path, attr string, value []byte) error {
+	return unix.Lsetxattr(path, attr, value, 0)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package extract
+
+// setXattr reports that extended attributes cannot be restored.
+func setXattr(path, attr string, value []byte) error {
+	return errUnsupported
+}
@@ -0,0 +1,49 @@
+package extract
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestEncodeACL(t *testing.T) {
+	got, err := encodeACL("user::rw-,user:not-a-user:r--:65534,group::r-x,mask::r-x,other::---")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []uint32{2}
+	for _, e := range [][3]uint32{
+		{aclUserObj, 6, aclNoID},
+		{aclUser, 4, 65534},
+		{aclGroupObj, 5, aclNoID},
+		{aclMask, 5, aclNoID},
+		{aclOther, 0, aclNoID},
+	} {
+		want = append(want, e[0]|e[1]<<16, e[2])
+	}
+	if len(got) != 4*len(want) {
+		t.Fatalf("expected %d bytes but got %d", 4*len(want), len(got))
+	}
+	for i, w := range want {
+		if v := binary.LittleEndian.Uint32(got[4*i:]); v != w {
+			t.Errorf("word %d: expected %#x but got %#x", i, w, v)
+		}
+	}
+
+	for _, text := range []string{"user", "bogus::rwx", "mask:foo:rwx", "user:no-such-user-here:rwx"} {
+		if _, err := encodeACL(text); err == nil {
+			t.Errorf("%s: expected error but got nil", text)
+		}
+	}
+}
+
+func TestZipOwner(t *testing.T) {
+	extra := []byte{0x75, 0x78, 11, 0, 1, 4, 0xd2, 0x04, 0, 0, 4, 0x2e, 0x16, 0, 0}
+	uid, gid, ok := zipOwner(extra)
+	if !ok || uid != 1234 || gid != 5678 {
+		t.Errorf("expected 1234:5678 but got %d:%d (%v)", uid, gid, ok)
+	}
+	if _, _, ok := zipOwner(extra[:8]); ok {
+		t.Errorf("expected a truncated field to be ignored")
+	}
+}
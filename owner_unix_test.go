@@ -0,0 +1,154 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func ownerArchive() *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{
+		Name:     "setuid",
+		Typeflag: tar.TypeReg,
+		Mode:     04755,
+		Size:     1,
+		Uid:      1234,
+		Gid:      5678,
+		PAXRecords: map[string]string{
+			"SCHILY.xattr.user.extract": "value",
+		},
+	})
+	tw.Write([]byte("x"))
+	tw.Close()
+	return &buf
+}
+
+func TestSameOwner(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_owner")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	dest := filepath.Join(parent, "default")
+	if _, err := ExtractStreamContext(context.Background(), ownerArchive(), dest, Options{}); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(dest, "setuid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSetuid != 0 {
+		t.Errorf("expected the setuid bit to be cleared but got %v", fi.Mode())
+	}
+
+	dest = filepath.Join(parent, "same")
+	res, err := ExtractStreamContext(context.Background(), ownerArchive(), dest, Options{SameOwner: true, PreservePermissions: true})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	file := filepath.Join(dest, "setuid")
+	fi, err = os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSetuid == 0 {
+		t.Errorf("expected the setuid bit to be kept but got %v", fi.Mode())
+	}
+
+	if os.Geteuid() != 0 {
+		if len(res.Warnings) == 0 {
+			t.Errorf("expected a warning when not running as root")
+		}
+		return
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && (st.Uid != 1234 || st.Gid != 5678) {
+		t.Errorf("expected owner 1234:5678 but got %d:%d", st.Uid, st.Gid)
+	}
+	for _, w := range res.Warnings {
+		if strings.Contains(w, "extended attribute") {
+			t.Skipf("extended attributes are not supported here: %s", w)
+		}
+	}
+	if len(res.Warnings) != 0 {
+		t.Errorf("expected no warnings but got %v", res.Warnings)
+	}
+}
+
+func TestDefaultMode(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_mode")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+	defer syscall.Umask(syscall.Umask(022))
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "open", Typeflag: tar.TypeReg, Mode: 0777})
+	tw.Close()
+
+	for i, tc := range []struct {
+		preserve bool
+		expect   os.FileMode
+	}{
+		{preserve: false, expect: 0755},
+		{preserve: true, expect: 0777},
+	} {
+		dir := filepath.Join(dest, strconv.Itoa(i))
+		if _, err := ExtractStreamContext(context.Background(), bytes.NewReader(buf.Bytes()), dir, Options{PreservePermissions: tc.preserve}); err != nil {
+			t.Fatalf("[%d] expected no error but got %v", i, err)
+		}
+		fi, err := os.Stat(filepath.Join(dir, "open"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != tc.expect {
+			t.Errorf("[%d] expected mode %v but got %v", i, tc.expect, fi.Mode().Perm())
+		}
+	}
+}
+
+func TestRestoreSymlink(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_mode")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+	outside, err := ioutil.TempDir("", "extract_outside")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(outside)
+	if err := os.Chmod(outside, 0700); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dest, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &tar.Header{Name: "link/", Typeflag: tar.TypeDir, Mode: 0777}
+	j := newJob(context.Background(), &Options{PreservePermissions: true}, &ArchiveResult{Destination: dest})
+	if err := j.restore(link, File{FileInfo: h.FileInfo(), Header: h}); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	fi, err := os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Errorf("expected the mode outside to be left but got %v", fi.Mode())
+	}
+}
@@ -0,0 +1,177 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxLinkHops bounds the symlinks followed when resolving a single path.
+const maxLinkHops = 255
+
+/*
+	CheckPath confirms that the path provided has not been made to
+	perform a traversal attack. See SecurePath for how the path is
+	resolved.
+*/
+func CheckPath(destination, filename string) (err error) {
+	_, err = SecurePath(destination, filename)
+	return err
+}
+
+/*
+	SecurePath resolves filename relative to the destination root and
+	returns the path that the entry should be written to. The path is
+	walked one component at a time, following any symlinks that already
+	exist beneath the root, so that a link written by an earlier entry
+	cannot be used to place a later one outside of it. A leading "/" is
+	treated as relative to the root.
+
+	An IllegalPathError is returned if a ".." component, or the target
+	of a symlink, would leave the root. The final component is not
+	followed, so that a symlink in that position is replaced rather
+	than written through.
+*/
+func SecurePath(destination, filename string) (string, error) {
+	return securePath(destination, filename, readSymlink)
+}
+
+/*
+	readSymlink returns the target of path when it is a symlink, and
+	whether it is one.
+*/
+func readSymlink(path string) (string, bool, error) {
+	fi, err := os.Lstat(path)
+	if err != nil || !IsSymlink(fi) {
+		return "", false, nil
+	}
+	target, err := os.Readlink(path)
+	return target, true, err
+}
+
+/*
+	securePath is SecurePath, finding the symlinks beneath the root
+	with readlink.
+*/
+func securePath(destination, filename string, readlink func(path string) (string, bool, error)) (string, error) {
+	root, err := filepath.Abs(destination)
+	if err != nil {
+		return "", fmt.Errorf("%s: resolving destination: %v", destination, err)
+	}
+	realRoot := root
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		realRoot = r
+	}
+	illegal := &IllegalPathError{Abs: filepath.Join(root, filename), Filename: filename}
+
+	var parts []string
+	pending := splitPath(filename)
+	for hops := 0; len(pending) > 0; {
+		c := pending[0]
+		pending = pending[1:]
+
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if len(parts) == 0 {
+				return "", illegal
+			}
+			parts = parts[:len(parts)-1]
+			continue
+		}
+
+		current := filepath.Join(root, filepath.Join(parts...), c)
+		if len(pending) == 0 {
+			parts = append(parts, c)
+			continue
+		}
+		target, ok, err := readlink(current)
+		if err != nil {
+			return "", fmt.Errorf("%s: reading symlink: %v", current, err)
+		}
+		if !ok {
+			parts = append(parts, c)
+			continue
+		}
+
+		hops++
+		if hops > maxLinkHops {
+			return "", fmt.Errorf("%s: too many levels of symbolic links", filename)
+		}
+		if filepath.IsAbs(target) {
+			rel, ok := within(root, target)
+			if !ok {
+				rel, ok = within(realRoot, target)
+			}
+			if !ok {
+				return "", illegal
+			}
+			parts = nil
+			target = rel
+		}
+		pending = append(splitPath(target), pending...)
+	}
+
+	return filepath.Join(root, filepath.Join(parts...)), nil
+}
+
+/*
+	linkedDir returns the directory that the symlink path points to, and
+	whether it points to one, once it is found to stay within root, as
+	SecurePath would find when writing through it.
+*/
+func linkedDir(root, path string) (string, bool, error) {
+	rel, ok := within(root, path)
+	if !ok {
+		return "", false, nil
+	}
+	// The trailing "." has SecurePath follow the link itself, as it is
+	// no longer the final component.
+	linked, err := SecurePath(root, rel+string(filepath.Separator)+".")
+	if err != nil {
+		return "", false, err
+	}
+	fi, err := os.Stat(linked)
+	if err != nil || !fi.IsDir() {
+		return "", false, nil
+	}
+	return linked, true, nil
+}
+
+/*
+	relName returns the path of destination relative to root, which
+	must be absolute, or destination itself when it is not within root.
+*/
+func relName(root, destination string) string {
+	if rel, ok := within(root, destination); ok {
+		return rel
+	}
+	return destination
+}
+
+/*
+	within reports whether the absolute path target is inside root, and
+	returns the path of target relative to root if so.
+*/
+func within(root, target string) (string, bool) {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return "", false
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+/*
+	splitPath splits an archive path into its components, accepting
+	both "/" and the separator of the host.
+*/
+func splitPath(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return r == '/' || r == filepath.Separator
+	})
+}
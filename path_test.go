@@ -0,0 +1,98 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecurePath(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_path")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	root := filepath.Join(parent, "out")
+	outside := filepath.Join(parent, "out-evil")
+	for _, dir := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"up":    "..",
+		"abs":   outside,
+		"inner": "sub",
+		"self":  filepath.Join(root, "sub"),
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, tc := range []struct {
+		name   string
+		expect string
+	}{
+		{name: "a/b", expect: "a/b"},
+		{name: "/a/b", expect: "a/b"},
+		{name: "a/../b", expect: "b"},
+		{name: "./sub/./c", expect: "sub/c"},
+		{name: "inner/c", expect: "sub/c"},
+		{name: "self/c", expect: "sub/c"},
+		{name: "up", expect: "up"},
+		{name: "abs", expect: "abs"},
+	} {
+		got, err := SecurePath(root, tc.name)
+		if err != nil {
+			t.Errorf("[%d] %s: expected no error but got %v", i, tc.name, err)
+			continue
+		}
+		if want := filepath.Join(root, tc.expect); got != want {
+			t.Errorf("[%d] %s: expected %s but got %s", i, tc.name, want, got)
+		}
+	}
+
+	for i, name := range []string{
+		"..",
+		"../out-evil/x",
+		"a/../../x",
+		"up/x",
+		"up/out/../out-evil/x",
+		"abs/x",
+	} {
+		_, err := SecurePath(root, name)
+		if !IsIllegalPathError(err) {
+			t.Errorf("[%d] %s: expected IllegalPathError but got %v", i, name, err)
+		}
+	}
+}
+
+func TestExtractSymlinkTraversal(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_symlink")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: parent})
+	tw.WriteHeader(&tar.Header{Name: "link/escaped", Typeflag: tar.TypeReg, Mode: 0644, Size: 1})
+	tw.Write([]byte("x"))
+	tw.Close()
+
+	// The link itself is allowed, so that it is the write through it
+	// that is rejected.
+	err = ExtractStream(&buf, filepath.Join(parent, "out"), Options{Links: AllowEscaping})
+	if !IsIllegalPathError(err) {
+		t.Errorf("expected IllegalPathError but got %v", err)
+	}
+	if FileExists(filepath.Join(parent, "escaped")) {
+		t.Errorf("file was written outside of the destination")
+	}
+}
@@ -0,0 +1,229 @@
+package extract
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// PlanAction is what a dry run found would happen to the path of an entry.
+type PlanAction int
+
+const (
+	// PlanCreate writes the entry to a path that does not yet exist.
+	PlanCreate PlanAction = iota
+
+	// PlanOverwrite replaces the file or link already at the path.
+	PlanOverwrite
+
+	// PlanSkip leaves the existing file in place, by the overwrite policy.
+	PlanSkip
+
+	/*
+		PlanReject refuses the entry, as its path or link target is not
+		allowed, or the overwrite policy is Fail and the path exists.
+	*/
+	PlanReject
+)
+
+var planActions = map[PlanAction]string{
+	PlanCreate:    "create",
+	PlanOverwrite: "overwrite",
+	PlanSkip:      "skip",
+	PlanReject:    "reject",
+}
+
+func (a PlanAction) String() string {
+	if s, ok := planActions[a]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+/*
+	PlanEntry is a change that a dry run found the extraction of an
+	archive would make. Path is relative to the destination of the
+	archive, and for an entry renamed by the overwrite policy is the
+	path it would be written to. Reason holds why an entry is rejected.
+*/
+type PlanEntry struct {
+	Path   string
+	Action PlanAction
+	Size   int64
+	Reason string
+}
+
+// deferredEntry is an entry of a dry run that is assessed once the top
+// level directory of the archive is known.
+type deferredEntry struct {
+	index int
+	fi    os.FileInfo
+}
+
+/*
+	plan records the change that writing the entry f to destination
+	would make, in place of writing it. The entries of a pending archive
+	are counted as they are found, but only assessed against what
+	exists once the top level directory is known, by dryRun.
+*/
+func (j *job) plan(destination string, f File) error {
+	if j.pending {
+		return j.entry(destination, 0, func() error {
+			j.deferred = append(j.deferred, deferredEntry{len(j.res.Plan), f.FileInfo})
+			j.res.Plan = append(j.res.Plan, PlanEntry{Path: j.name(destination), Size: f.Size()})
+			delete(j.links, destination)
+			return nil
+		})
+	}
+
+	p, ok := j.assess(destination, f.FileInfo)
+	if !ok {
+		return nil
+	}
+	p.Size = f.Size()
+	j.res.Plan = append(j.res.Plan, p)
+	if p.Action == PlanSkip || p.Action == PlanReject {
+		return nil
+	}
+	return j.entry(destination, 0, func() error { return nil })
+}
+
+/*
+	planLink plans the symlink f to link at destination. Once it would
+	be written there, later entries are resolved through it, as they
+	would be once it exists.
+*/
+func (j *job) planLink(destination, link string, f File) error {
+	n := len(j.res.Plan)
+	if err := j.plan(destination, f); err != nil || len(j.res.Plan) == n {
+		return err
+	}
+	p := j.res.Plan[n]
+	if p.Path != j.name(destination) || (p.Action != PlanCreate && p.Action != PlanOverwrite) {
+		return nil
+	}
+	if j.links == nil {
+		j.links = make(map[string]string)
+	}
+	j.links[destination] = link
+	return nil
+}
+
+/*
+	assess applies the overwrite policy to the entry fi that is to be
+	written to destination, returning the change it would make, and
+	records the path as written for the entries after it. Directories
+	that already exist are merged, so are left out of the plan.
+*/
+func (j *job) assess(destination string, fi os.FileInfo) (PlanEntry, bool) {
+	existing, err := j.lstat(destination)
+	exists := err == nil
+	if fi.IsDir() {
+		if exists && existing.IsDir() {
+			return PlanEntry{}, false
+		}
+		j.written(destination, fi)
+		return PlanEntry{Path: j.name(destination), Action: PlanCreate}, true
+	}
+
+	to, ok, err := j.conflict(destination, fi.ModTime())
+	switch {
+	case err != nil:
+		return PlanEntry{Path: j.name(destination), Action: PlanReject, Reason: err.Error()}, true
+	case !ok:
+		return PlanEntry{Path: j.name(destination), Action: PlanSkip}, true
+	}
+	j.written(to, fi)
+	if to != destination || !exists {
+		return PlanEntry{Path: j.name(to), Action: PlanCreate}, true
+	}
+	return PlanEntry{Path: j.name(destination), Action: PlanOverwrite}, true
+}
+
+// written records that a dry run would write the entry fi to path.
+func (j *job) written(path string, fi os.FileInfo) {
+	if j.planned == nil {
+		j.planned = make(map[string]os.FileInfo)
+	}
+	j.planned[path] = fi
+	delete(j.links, path)
+}
+
+/*
+	lstat is os.Lstat, also finding the paths that a dry run would have
+	written.
+*/
+func (j *job) lstat(path string) (os.FileInfo, error) {
+	if fi, ok := j.planned[path]; ok {
+		return fi, nil
+	}
+	return os.Lstat(path)
+}
+
+/*
+	readlink is readSymlink, also finding the symlinks that a dry run
+	would have written.
+*/
+func (j *job) readlink(path string) (string, bool, error) {
+	if link, ok := j.links[path]; ok {
+		return link, true, nil
+	}
+	if _, ok := j.planned[path]; ok {
+		return "", false, nil
+	}
+	return readSymlink(path)
+}
+
+/*
+	reject records the entry name as rejected by err during a dry run,
+	which carries on with the next entry. Outside of a dry run err is
+	returned as it is.
+*/
+func (j *job) reject(name string, err error) error {
+	if !j.opts.DryRun {
+		return err
+	}
+	j.res.Plan = append(j.res.Plan, PlanEntry{Path: name, Action: PlanReject, Reason: err.Error()})
+	return nil
+}
+
+/*
+	dryRun calls extract in place of staged for a dry run, which writes
+	nothing. Once the entries of a pending archive are known, its top
+	level directory is chosen and each entry is assessed against what
+	exists there, with those skipped or rejected no longer counted.
+*/
+func (j *job) dryRun(destination string, extract func(destination string) error) error {
+	err := extract(destination)
+	if err != nil || !j.pending {
+		return err
+	}
+
+	final, err := j.topLevel(destination)
+	if err != nil {
+		return err
+	}
+	j.res.Destination = final
+	j.pending = false
+
+	plan := j.res.Plan
+	j.res.Plan = nil
+	next := 0
+	for i, p := range plan {
+		if next == len(j.deferred) || j.deferred[next].index != i {
+			j.res.Plan = append(j.res.Plan, p)
+			continue
+		}
+		d := j.deferred[next]
+		next++
+
+		assessed, ok := j.assess(filepath.Join(final, p.Path), d.fi)
+		if ok {
+			assessed.Size = p.Size
+			j.res.Plan = append(j.res.Plan, assessed)
+		}
+		if !ok || assessed.Action == PlanSkip || assessed.Action == PlanReject {
+			j.res.Entries--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_plan")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	for i, file := range []string{"testdata/test.tar.gz", "testdata/test.zip", "testdata/test.rar", "testdata/test.gz"} {
+		for _, top := range []TopLevelPolicy{Auto, Always} {
+			res, err := ExtractContext(context.Background(), []string{file}, Options{Destination: dest, DryRun: true, TopLevel: top})
+			if err != nil {
+				t.Fatalf("[%d] %s: expected no error but got %v", i, file, err)
+			}
+			a := res.Archives[0]
+			if len(a.Plan) == 0 || len(a.Plan) != a.Entries {
+				t.Errorf("[%d] %s: expected a plan of %d entries but got %d", i, file, a.Entries, len(a.Plan))
+			}
+			for _, p := range a.Plan {
+				if p.Action != PlanCreate {
+					t.Errorf("[%d] %s: expected %s to be created but got %v", i, file, p.Path, p.Action)
+				}
+			}
+		}
+	}
+	if entries, _ := ioutil.ReadDir(dest); len(entries) != 0 {
+		t.Errorf("expected nothing to be written but found %d entries", len(entries))
+	}
+}
+
+func TestDryRunOverwrite(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_plan")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	existing := filepath.Join(dest, "test", "80nj")
+	if err := WriteFile(existing, bytes.NewBufferString("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range []struct {
+		policy OverwritePolicy
+		path   string
+		expect PlanAction
+	}{
+		{policy: Overwrite, path: "test/80nj", expect: PlanOverwrite},
+		{policy: Skip, path: "test/80nj", expect: PlanSkip},
+		{policy: Rename, path: "test/80nj.1", expect: PlanCreate},
+		{policy: Fail, path: "test/80nj", expect: PlanReject},
+	} {
+		for _, file := range []string{"testdata/test.tar", "testdata/test.zip"} {
+			res, err := ExtractContext(context.Background(), []string{file}, Options{Destination: dest, DryRun: true, Overwrite: tc.policy})
+			if err != nil {
+				t.Fatalf("[%d] %s: expected no error but got %v", i, file, err)
+			}
+			var found bool
+			for _, p := range res.Archives[0].Plan {
+				if p.Path == filepath.FromSlash(tc.path) {
+					found = true
+					if p.Action != tc.expect {
+						t.Errorf("[%d] %s: expected %s to be planned as %v but got %v", i, file, tc.path, tc.expect, p.Action)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("[%d] %s: expected %s in the plan", i, file, tc.path)
+			}
+		}
+	}
+	if got, _ := ioutil.ReadFile(existing); string(got) != "old" {
+		t.Errorf("expected existing file to be kept but it holds %q", got)
+	}
+}
+
+func TestDryRunReject(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_plan")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0644, Size: 1})
+	tw.Write([]byte("x"))
+	tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.WriteHeader(&tar.Header{Name: "ok", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.Close()
+
+	res, err := ExtractStreamContext(context.Background(), &buf, dest, Options{DryRun: true, TopLevel: Never})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	expect := []PlanEntry{
+		{Path: "../escape", Action: PlanReject},
+		{Path: "link", Action: PlanReject},
+		{Path: "ok", Action: PlanCreate},
+	}
+	if len(res.Plan) != len(expect) {
+		t.Fatalf("expected %d planned entries but got %v", len(expect), res.Plan)
+	}
+	for i, p := range res.Plan {
+		if p.Path != expect[i].Path || p.Action != expect[i].Action {
+			t.Errorf("[%d] expected %s to be planned as %v but got %s as %v", i, expect[i].Path, expect[i].Action, p.Path, p.Action)
+		}
+	}
+	if res.Plan[0].Reason == "" {
+		t.Errorf("expected a reason for the rejected entry")
+	}
+	if res.Entries != 1 {
+		t.Errorf("expected 1 entry but got %d", res.Entries)
+	}
+	if FileExists(filepath.Join(dest, "ok")) {
+		t.Errorf("expected nothing to be written")
+	}
+}
+
+func TestDryRunPlanned(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_plan")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < 2; i++ {
+		tw.WriteHeader(&tar.Header{Name: "dup", Typeflag: tar.TypeReg, Mode: 0644, Size: 1})
+		tw.Write([]byte("x"))
+	}
+	tw.WriteHeader(&tar.Header{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "/elsewhere"})
+	tw.WriteHeader(&tar.Header{Name: "a/x", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.Close()
+
+	for i, tc := range []struct {
+		policy OverwritePolicy
+		top    TopLevelPolicy
+		expect []PlanAction
+	}{
+		{policy: Overwrite, top: Never, expect: []PlanAction{PlanCreate, PlanOverwrite, PlanCreate, PlanReject}},
+		{policy: Skip, top: Never, expect: []PlanAction{PlanCreate, PlanSkip, PlanCreate, PlanReject}},
+		{policy: Overwrite, top: Auto, expect: []PlanAction{PlanCreate, PlanOverwrite, PlanCreate, PlanReject}},
+	} {
+		opts := Options{DryRun: true, Overwrite: tc.policy, TopLevel: tc.top, Links: AllowAbsolute}
+		res, err := ExtractStreamContext(context.Background(), bytes.NewReader(buf.Bytes()), dest, opts)
+		if err != nil {
+			t.Fatalf("[%d] expected no error but got %v", i, err)
+		}
+		if len(res.Plan) != len(tc.expect) {
+			t.Fatalf("[%d] expected %d planned entries but got %v", i, len(tc.expect), res.Plan)
+		}
+		for k, p := range res.Plan {
+			if p.Action != tc.expect[k] {
+				t.Errorf("[%d] expected %s to be planned as %v but got %v", i, p.Path, tc.expect[k], p.Action)
+			}
+		}
+	}
+	if entries, _ := ioutil.ReadDir(dest); len(entries) != 0 {
+		t.Errorf("expected nothing to be written but found %d entries", len(entries))
+	}
+}
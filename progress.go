@@ -0,0 +1,119 @@
+package extract
+
+import "time"
+
+/*
+	ProgressReporter receives events as archives are extracted. Archives
+	may be extracted concurrently, so implementations must be safe for
+	concurrent use, and tell archives apart by their name.
+
+	Entry names are the paths the entries are written to, relative to
+	the destination of the archive. Sizes that are not known ahead of
+	time are reported as -1. BytesRead reports the compressed bytes
+	consumed from the archive, and BytesWritten the bytes written out.
+
+	ArchiveDone is reported once for every archive, including those that
+	fail, or are cancelled, before ArchiveStart is reported.
+*/
+type ProgressReporter interface {
+	ArchiveStart(archive string, size int64)
+	EntryStart(archive, name string, size int64)
+	BytesRead(archive string, n int64)
+	BytesWritten(archive string, n int64)
+	EntryDone(archive, name string, err error)
+	ArchiveDone(archive string, err error)
+}
+
+/*
+	NopProgress is a ProgressReporter that discards all events. It is
+	used when no reporter is set in the Options.
+*/
+type NopProgress struct{}
+
+func (NopProgress) ArchiveStart(archive string, size int64)     {}
+func (NopProgress) EntryStart(archive, name string, size int64) {}
+func (NopProgress) BytesRead(archive string, n int64)           {}
+func (NopProgress) BytesWritten(archive string, n int64)        {}
+func (NopProgress) EntryDone(archive, name string, err error)   {}
+func (NopProgress) ArchiveDone(archive string, err error)       {}
+
+// ProgressEventKind identifies the event held in a ProgressEvent.
+type ProgressEventKind int
+
+const (
+	EventArchiveStart ProgressEventKind = iota
+	EventEntryStart
+	EventBytesWritten
+	EventEntryDone
+	EventArchiveDone
+	EventBytesRead
+)
+
+func (k ProgressEventKind) String() string {
+	switch k {
+	case EventArchiveStart:
+		return "archive-start"
+	case EventEntryStart:
+		return "entry-start"
+	case EventBytesWritten:
+		return "bytes-written"
+	case EventEntryDone:
+		return "entry-done"
+	case EventArchiveDone:
+		return "archive-done"
+	case EventBytesRead:
+		return "bytes-read"
+	}
+	return "unknown"
+}
+
+/*
+	ProgressEvent is a single event sent by a ChannelProgress. Size holds
+	the size of the archive or entry for start events, and the number of
+	bytes for EventBytesRead and EventBytesWritten.
+*/
+type ProgressEvent struct {
+	Kind    ProgressEventKind
+	Time    time.Time
+	Archive string
+	Entry   string
+	Size    int64
+	Err     error
+}
+
+/*
+	ChannelProgress is a ProgressReporter that sends each event on the
+	channel, for services that report progress elsewhere. Sends block,
+	so the channel must be drained for the extraction to continue. The
+	channel is not closed once the extraction is complete.
+*/
+type ChannelProgress chan<- ProgressEvent
+
+func (c ChannelProgress) send(ev ProgressEvent) {
+	ev.Time = time.Now()
+	c <- ev
+}
+
+func (c ChannelProgress) ArchiveStart(archive string, size int64) {
+	c.send(ProgressEvent{Kind: EventArchiveStart, Archive: archive, Size: size})
+}
+
+func (c ChannelProgress) EntryStart(archive, name string, size int64) {
+	c.send(ProgressEvent{Kind: EventEntryStart, Archive: archive, Entry: name, Size: size})
+}
+
+func (c ChannelProgress) BytesRead(archive string, n int64) {
+	c.send(ProgressEvent{Kind: EventBytesRead, Archive: archive, Size: n})
+}
+
+func (c ChannelProgress) BytesWritten(archive string, n int64) {
+	c.send(ProgressEvent{Kind: EventBytesWritten, Archive: archive, Size: n})
+}
+
+func (c ChannelProgress) EntryDone(archive, name string, err error) {
+	c.send(ProgressEvent{Kind: EventEntryDone, Archive: archive, Entry: name, Err: err})
+}
+
+func (c ChannelProgress) ArchiveDone(archive string, err error) {
+	c.send(ProgressEvent{Kind: EventArchiveDone, Archive: archive, Err: err})
+}
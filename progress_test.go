@@ -0,0 +1,95 @@
+package extract
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vbauerster/mpb/v7"
+)
+
+func TestChannelProgress(t *testing.T) {
+	testParent, err := ioutil.TempDir("", "extract_progress")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(testParent)
+
+	ch := make(chan ProgressEvent)
+	var events []ProgressEvent
+	done := make(chan struct{})
+	go func() {
+		for ev := range ch {
+			events = append(events, ev)
+		}
+		close(done)
+	}()
+
+	res, err := ExtractContext(context.Background(), []string{"testdata/test.tar"}, Options{
+		Destination: testParent,
+		Progress:    ChannelProgress(ch),
+	})
+	close(ch)
+	<-done
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+
+	if len(events) == 0 {
+		t.Fatalf("expected progress events but got none")
+	}
+	if events[0].Kind != EventArchiveStart || events[len(events)-1].Kind != EventArchiveDone {
+		t.Errorf("expected events to begin with %s and end with %s but got %s and %s",
+			EventArchiveStart, EventArchiveDone, events[0].Kind, events[len(events)-1].Kind)
+	}
+
+	counts := make(map[ProgressEventKind]int)
+	var written int64
+	for _, ev := range events {
+		counts[ev.Kind]++
+		if ev.Kind == EventBytesWritten {
+			written += ev.Size
+		}
+		if ev.Archive != "testdata/test.tar" {
+			t.Errorf("unexpected archive %s in %s event", ev.Archive, ev.Kind)
+		}
+	}
+	r := res.Archives[0]
+	if counts[EventEntryStart] != r.Entries || counts[EventEntryDone] != r.Entries {
+		t.Errorf("expected %d entry events but got %d started and %d done",
+			r.Entries, counts[EventEntryStart], counts[EventEntryDone])
+	}
+	if written != r.Bytes {
+		t.Errorf("expected %d bytes to be reported but got %d", r.Bytes, written)
+	}
+}
+
+func TestMPBProgressCompletes(t *testing.T) {
+	testParent, err := ioutil.TempDir("", "extract_progress")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(testParent)
+
+	files := []string{"testdata/test.tar.gz", "testdata/test.zip", "testdata/test.txt"}
+	p := mpb.New(mpb.WithOutput(ioutil.Discard))
+	progress := NewMPBProgress(p)
+	progress.AddTotalBar(files)
+	ExtractContext(context.Background(), files, Options{
+		Destination: testParent,
+		Progress:    progress,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("expected all bars to complete")
+	}
+}
@@ -4,13 +4,10 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"sync/atomic"
 	"time"
 
 	"github.com/Galzzly/extract/v2/internal/magic"
 	"github.com/nwaples/rardecode"
-	"github.com/vbauerster/mpb/v7"
 )
 
 type Rar struct {
@@ -26,102 +23,112 @@ type Rar struct {
 	the function will not return any error.
 */
 func (*Rar) CheckFormat(filename string) error {
-	l := atomic.LoadUint32(&readLimit)
-
-	// Get the header of filename
-	h, err := GetFileHeader(filename, l)
+	ok, err := detectFile(filename, isRar)
 	if err != nil {
 		return fmt.Errorf("problem looking at %s", filename)
 	}
-	mu.Lock()
-	defer mu.Unlock()
-
-	// Check if the file is a Rar file
-	var m = newMime("Rar", magic.Rar)
-	if !m.detector(h, l) {
+	if !ok {
 		return fmt.Errorf("%s is not a Rar file", filename)
 	}
 	return nil
 }
 
+// isRar reports whether s is the start of a rar archive.
+func isRar(s *Sniff) bool {
+	return magic.Rar(s.Head, s.limit)
+}
+
 /*
 	Extract will extract the file sent to the function
 */
-func (rar *Rar) Extract(filename, destination string, p *mpb.Progress, start time.Time) (err error) {
-	b := AddNewBar(p, filename, start)
-	// Check for a common root, and return a modified destination
-	// so that we don't clobber the destination directory
-	destination, err = rar.topLevelDir(filename, destination)
+func (rar *Rar) Extract(filename, destination string, p ProgressReporter) (err error) {
+	return extractArchive(rar, filename, destination, p)
+}
+
+func (rar *Rar) extract(j *job, filename, destination string) (err error) {
+	destination, err = rar.topLevelDir(j, filename, destination)
 	if err != nil {
-		b.Abort(true)
-		return
+		return err
 	}
+	j.res.Destination = destination
 
 	// Open up the Rar file for reading
 	// Supporting multi-volume archives.
 	err = rar.OpenRarFile(filename)
 	if err != nil {
-		b.Abort(true)
 		return fmt.Errorf("unable to open rar file for reading: %v", err)
 	}
 	defer rar.Close()
 
-	for {
-		err = rar.unrarNextFile(destination)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			b.Abort(true)
-			return fmt.Errorf("issue reading file in rar archive: %v", err)
-		}
-	}
-	b.SetTotal(1, true)
-	return nil
+	return rar.extractAll(j, destination)
 }
 
 /*
-	topLevelDir will evaluate contents of the Rar file and checks for
-	a common root directory. If the root directory is found, the
-	destination will be modified to be relative to the root directory.
+	topLevelDir returns the directory within destination that the
+	archive filename is extracted into. When that depends on the top
+	level entries, their headers are read in a first pass, so that the
+	entries can then be written directly into place. The destination of
+	a deferred archive is left as it is, to be chosen once extracted.
 */
-func (rar *Rar) topLevelDir(source, destination string) (string, error) {
-	f, err := os.Open(source)
-	if err != nil {
-		return "", fmt.Errorf("error opening %s: %v", source, err)
+func (rar *Rar) topLevelDir(j *job, filename, destination string) (string, error) {
+	if j.pending {
+		return destination, nil
 	}
-	defer f.Close()
+	if j.needsTops() {
+		if err := rar.readTops(j, filename); err != nil {
+			return "", err
+		}
+	}
+	return j.topLevel(destination)
+}
 
-	r, err := rardecode.NewReader(f, "")
+// readTops records the top level elements of the entries of filename.
+func (rar *Rar) readTops(j *job, filename string) error {
+	err := rar.OpenRarFile(filename)
 	if err != nil {
-		return "", fmt.Errorf("unable to open rar archive: %v", err)
+		return fmt.Errorf("unable to open rar file for reading: %v", err)
 	}
+	defer rar.Close()
 
-	// Get the files in the Rar file
-	var files []string
 	for {
-		f, err := r.Next()
+		if err := j.err(); err != nil {
+			return err
+		}
+		f, err := rar.Read()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			return "", fmt.Errorf("issue scanning rar file listings: %v", err)
+			return fmt.Errorf("issue reading the rar archive headers: %w", err)
+		}
+		fh := f.Header.(*rardecode.FileHeader)
+		if !j.selected(fh.Name, f) {
+			continue
+		}
+		if name, ok := j.remap(fh.Name); ok {
+			j.addTop(name)
 		}
-		files = append(files, f.Name)
 	}
+}
 
-	if TopLevels(files) {
-		destination = filepath.Join(destination, DirFromFile(source))
+/*
+	extractAll will extract every remaining file in the open archive
+*/
+func (rar *Rar) extractAll(j *job, destination string) error {
+	err := j.extractFrom(func() error {
+		return rar.unrarNextFile(j, destination)
+	})
+	if err != nil {
+		return fmt.Errorf("issue reading file in rar archive: %w", err)
 	}
-
-	return destination, nil
+	return nil
 }
 
 /*
 	unrarNextFile will read the next file in the Rar archive, check the path
 	and move on to perform the extraction via unrarFile
 */
-func (rar *Rar) unrarNextFile(destination string) (err error) {
+func (rar *Rar) unrarNextFile(j *job, destination string) (err error) {
 	f, err := rar.Read()
 	if err != nil {
 		return
@@ -133,33 +140,45 @@ func (rar *Rar) unrarNextFile(destination string) (err error) {
 		return fmt.Errorf("expected header to be *rardecode.FileHeader but found %T", f.Header)
 	}
 
-	// err = rar.CheckPath(destination, fh.Name)
-	err = CheckPath(destination, fh.Name)
+	// Volumes opened by name are read within rardecode, so the bytes
+	// consumed are taken from the header as each file is read.
+	if rar.rc != nil {
+		j.read(fh.PackedSize)
+	}
+	if !j.selected(fh.Name, f) {
+		return nil
+	}
+	name, ok := j.remap(fh.Name)
+	if !ok {
+		return nil
+	}
+
+	dest, err := j.resolve(destination, name)
 	if err != nil {
-		return fmt.Errorf("checking path: %v", err)
+		return
 	}
 
-	return rar.unrarFile(f, filepath.Join(destination, fh.Name))
+	return rar.unrarFile(j, f, dest)
 }
 
 /*
 	unrarFile will extract the file sent to the function
 */
-func (rar *Rar) unrarFile(f File, destination string) (err error) {
+func (rar *Rar) unrarFile(j *job, f File, destination string) (err error) {
 	fh, ok := f.Header.(*rardecode.FileHeader)
 	if !ok {
 		return fmt.Errorf("expected header to be *rardecode.FileHeader but found %T", f.Header)
 	}
 
 	if f.IsDir() {
-		return Mkdir(destination, fh.Mode())
+		return j.mkdir(destination, f)
 	}
 
 	if (fh.Mode() & os.ModeSymlink) != 0 {
 		return nil
 	}
 
-	return WriteFile(destination, rar.rr, fh.Mode())
+	return j.writeFile(destination, f)
 }
 
 /*
@@ -214,6 +233,11 @@ func (rar *Rar) Close() (err error) {
 	return
 }
 
+func init() {
+	RegisterFormat("rar", isRar, func() Extractor { return NewRar() }, 0)
+	RegisterExtensions("rar", ".rar")
+}
+
 func NewRar() *Rar {
 	return &Rar{
 		MkdirAll: true,
@@ -0,0 +1,142 @@
+package extract
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+	remaps reports whether the names of entries are changed as they are
+	extracted. The Auto policy does not wrap such archives in a
+	directory, as the names are given explicitly.
+*/
+func (j *job) remaps() bool {
+	return j.opts.StripComponents > 0 || j.opts.Rename != nil
+}
+
+/*
+	remap returns the name that the entry name is extracted as, once
+	StripComponents and then Rename are applied, or false when nothing
+	is left of it.
+*/
+func (j *job) remap(name string) (string, bool) {
+	if n := j.opts.StripComponents; n > 0 {
+		var parts []string
+		for _, p := range splitPath(name) {
+			if p != "." {
+				parts = append(parts, p)
+			}
+		}
+		if len(parts) <= n {
+			return "", false
+		}
+		name = strings.Join(parts[n:], "/")
+	}
+	if j.opts.Rename != nil {
+		name = j.opts.Rename(name)
+	}
+	return name, name != ""
+}
+
+/*
+	ParseTransform returns a function for Options.Rename that applies
+	the sed replacement expression expr, as in "s/old/new/", to each
+	name. Any character may be used as the delimiter in place of "/".
+	The replacement may refer to the whole match with "&" and to groups
+	with "\1" to "\9". The flags "g", to replace every match rather
+	than the first, and "i", to ignore case, may follow the expression.
+*/
+func ParseTransform(expr string) (func(name string) string, error) {
+	if len(expr) < 2 || expr[0] != 's' {
+		return nil, fmt.Errorf("invalid transform %q: expected s/old/new/", expr)
+	}
+	delim := expr[1:2]
+	parts := splitUnescaped(expr[2:], delim[0])
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid transform %q: expected s%sold%snew%s", expr, delim, delim, delim)
+	}
+	pattern, replacement, flags := parts[0], parts[1], parts[2]
+
+	global := false
+	for _, c := range flags {
+		switch c {
+		case 'g':
+			global = true
+		case 'i':
+			pattern = "(?i)" + pattern
+		default:
+			return nil, fmt.Errorf("invalid transform %q: unknown flag %q", expr, c)
+		}
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transform %q: %v", expr, err)
+	}
+	template := sedTemplate(replacement)
+
+	return func(name string) string {
+		if global {
+			return re.ReplaceAllString(name, template)
+		}
+		m := re.FindStringSubmatchIndex(name)
+		if m == nil {
+			return name
+		}
+		out := re.ExpandString(nil, template, name, m)
+		return name[:m[0]] + string(out) + name[m[1]:]
+	}, nil
+}
+
+/*
+	splitUnescaped splits s at each delim that is not escaped with a
+	backslash, removing the escape from the delimiters.
+*/
+func splitUnescaped(s string, delim byte) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == delim:
+			b.WriteByte(delim)
+			i++
+		case s[i] == '\\' && i+1 < len(s):
+			b.WriteByte(s[i])
+			b.WriteByte(s[i+1])
+			i++
+		case s[i] == delim:
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+// sedTemplate converts a sed replacement to a regexp template.
+func sedTemplate(replacement string) string {
+	var b strings.Builder
+	for i := 0; i < len(replacement); i++ {
+		c := replacement[i]
+		switch {
+		case c == '$':
+			b.WriteString("$$")
+		case c == '&':
+			b.WriteString("${0}")
+		case c == '\\' && i+1 < len(replacement):
+			i++
+			if n := replacement[i]; n >= '0' && n <= '9' {
+				b.WriteString("${" + string(n) + "}")
+			} else if n == '$' {
+				b.WriteString("$$")
+			} else {
+				b.WriteByte(n)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
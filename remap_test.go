@@ -0,0 +1,97 @@
+package extract
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTransform(t *testing.T) {
+	for i, tc := range []struct {
+		expr   string
+		name   string
+		expect string
+	}{
+		{expr: "s/old/new/", name: "old/old", expect: "new/old"},
+		{expr: "s/old/new/g", name: "old/old", expect: "new/new"},
+		{expr: "s/OLD/new/i", name: "old/x", expect: "new/x"},
+		{expr: `s/^\(project\)-[0-9.]*/\1/`, name: "project-1.2.3/bin", expect: "project-1.2.3/bin"},
+		{expr: `s/^(project)-[0-9.]*/\1/`, name: "project-1.2.3/bin", expect: "project/bin"},
+		{expr: "s/^docs/share\\/doc/", name: "docs/README", expect: "share/doc/README"},
+		{expr: "s|^docs|share/&|", name: "docs/README", expect: "share/docs/README"},
+		{expr: `s/x/\&$1/`, name: "axb", expect: "a&$1b"},
+		{expr: "s/^/prefix\\//", name: "a", expect: "prefix/a"},
+		{expr: "s/nomatch/x/", name: "a", expect: "a"},
+	} {
+		rename, err := ParseTransform(tc.expr)
+		if err != nil {
+			t.Errorf("[%d] %s: expected no error but got %v", i, tc.expr, err)
+			continue
+		}
+		if got := rename(tc.name); got != tc.expect {
+			t.Errorf("[%d] %s: expected %q but got %q", i, tc.expr, tc.expect, got)
+		}
+	}
+
+	for _, expr := range []string{"", "s", "y/a/b/", "s/a/b", "s/a/b/c/", "s/(/x/", "s/a/b/q"} {
+		if _, err := ParseTransform(expr); err == nil {
+			t.Errorf("%q: expected error but got nil", expr)
+		}
+	}
+}
+
+func TestRemap(t *testing.T) {
+	for i, tc := range []struct {
+		strip  int
+		name   string
+		expect string
+	}{
+		{strip: 0, name: "a/b", expect: "a/b"},
+		{strip: 1, name: "a/b/c", expect: "b/c"},
+		{strip: 1, name: "./a/b", expect: "b"},
+		{strip: 2, name: "/a/b/c/", expect: "c"},
+		{strip: 1, name: "a/"},
+		{strip: 3, name: "a/b"},
+	} {
+		j := &job{opts: &Options{StripComponents: tc.strip}}
+		got, ok := j.remap(tc.name)
+		if ok != (tc.expect != "") || got != tc.expect {
+			t.Errorf("[%d] %s: expected %q but got %q (%v)", i, tc.name, tc.expect, got, ok)
+		}
+	}
+}
+
+func TestExtractStripComponents(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_strip")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	rename, err := ParseTransform("s/^xeso/renamed/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"testdata/test.tar", "testdata/test.zip", "testdata/test.rar"} {
+		dest := filepath.Join(parent, filepath.Base(file))
+		opts := Options{Destination: dest, StripComponents: 1, Rename: rename}
+		if _, err := ExtractContext(context.Background(), []string{file}, opts); err != nil {
+			t.Fatalf("%s: expected no error but got %v", file, err)
+		}
+		for _, name := range []string{"0dmnf3/f2eeblv6", "80nj", "renamed/bw7yzbpm"} {
+			if !FileExists(filepath.Join(dest, name)) {
+				t.Errorf("%s: expected %s to be extracted", file, name)
+			}
+		}
+	}
+
+	// Remapped names are still confined to the destination.
+	escape := func(name string) string { return "../" + name }
+	dest := filepath.Join(parent, "escape")
+	_, err = ExtractContext(context.Background(), []string{"testdata/test.tar"}, Options{Destination: dest, Rename: escape})
+	if !IsIllegalPathError(err) {
+		t.Errorf("expected IllegalPathError but got %v", err)
+	}
+}
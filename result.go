@@ -0,0 +1,68 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+	Result reports the outcome of an extraction, with one ArchiveResult
+	for each archive requested, in the order they were given.
+*/
+type Result struct {
+	Archives []ArchiveResult
+}
+
+/*
+	Err returns the errors of every archive that failed, joined together
+	with each prefixed by the name of its archive. It returns nil when
+	all of the archives were extracted.
+*/
+func (r *Result) Err() error {
+	var errs []error
+	for _, a := range r.Archives {
+		if a.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", a.Archive, a.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+	ArchiveResult reports the outcome of extracting a single archive.
+	Archives that were not started because the extraction was cancelled
+	have Err set to the error of the context.
+*/
+type ArchiveResult struct {
+	Archive     string
+	Format      string
+	Destination string
+
+	// Entries is the number of files, directories and links written,
+	// and Bytes the total size of the files written.
+	Entries int
+	Bytes   int64
+
+	// Skipped lists the entries left in place by the overwrite policy.
+	Skipped []string
+
+	/*
+		Warnings lists the problems that did not stop the extraction,
+		such as owners that could not be restored.
+	*/
+	Warnings []string
+
+	/*
+		Plan lists the changes that extracting the archive would make,
+		in the order the entries are found, when Options.DryRun is set.
+	*/
+	Plan []PlanEntry
+
+	// Manifest holds the checksums of the files written, when
+	// Options.Checksum is set.
+	Manifest *Manifest
+
+	Duration time.Duration
+	Err      error
+}
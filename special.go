@@ -0,0 +1,164 @@
+package extract
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sparseBlock is the size of the runs of zeros written as holes.
+const sparseBlock = 4096
+
+/*
+	errSkipEntry is returned by the write of an entry that was skipped,
+	or in place of the path of an entry rejected during a dry run.
+*/
+var errSkipEntry = errors.New("entry skipped")
+
+/*
+	WriteDevice creates the device node or named pipe described by mode
+	at the destination path. Device nodes can usually only be created
+	when running as root.
+*/
+func WriteDevice(destination string, mode os.FileMode, major, minor int64) (err error) {
+	err = Mkdir(filepath.Dir(destination), 0755)
+	if err != nil {
+		return fmt.Errorf("%s: error creating parent directories: %v", destination, err)
+	}
+
+	_, err = os.Lstat(destination)
+	if err == nil {
+		err = os.Remove(destination)
+		if err != nil {
+			return fmt.Errorf("%s: error removing existing file: %v", destination, err)
+		}
+	}
+	err = mknod(destination, mode, major, minor)
+	if err != nil {
+		return fmt.Errorf("%s: error creating device: %w", destination, err)
+	}
+	return nil
+}
+
+/*
+	WriteSparseFile writes a file to the destination path like WriteFile,
+	leaving holes in place of the blocks of in that are all zeros.
+*/
+func WriteSparseFile(destination string, in io.Reader, mode os.FileMode) (err error) {
+	out, err := createFile(destination, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	sw := &sparseWriter{f: out}
+	_, err = io.Copy(sw, in)
+	if err == nil {
+		err = sw.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: error writing file: %w", destination, err)
+	}
+	return out.Close()
+}
+
+/*
+	sparseWriter writes to a file, seeking past the blocks that are all
+	zeros so that they are left as holes.
+*/
+type sparseWriter struct {
+	f   *os.File
+	off int64
+}
+
+func (sw *sparseWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		// Blocks are aligned to the offset in the file, so that holes
+		// can be left wherever a whole block is zeros.
+		n := sparseBlock - int(sw.off%sparseBlock)
+		if n > len(b) {
+			n = len(b)
+		}
+		chunk := b[:n]
+
+		if isZeros(chunk) {
+			if _, err := sw.f.Seek(int64(n), io.SeekCurrent); err != nil {
+				return written, err
+			}
+		} else if _, err := sw.f.Write(chunk); err != nil {
+			return written, err
+		}
+		sw.off += int64(n)
+		written += n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// Close sets the size of the file, in case it ends with a hole.
+func (sw *sparseWriter) Close() error {
+	return sw.f.Truncate(sw.off)
+}
+
+func isZeros(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+	isSparse reports whether f is a sparse file in its archive, which
+	for tar is either of the GNU sparse formats.
+*/
+func isSparse(f File) bool {
+	h, ok := f.Header.(*tar.Header)
+	if !ok {
+		return false
+	}
+	if h.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range h.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	device creates the device node or named pipe f at the destination
+	path. Those that cannot be created, as they need privileges that
+	the process does not have, are skipped with a warning.
+*/
+func (j *job) device(destination string, f File, major, minor int64) error {
+	if j.opts.DryRun {
+		return j.plan(destination, f)
+	}
+	destination, ok, err := j.conflict(destination, f.ModTime())
+	if !ok {
+		return err
+	}
+	return j.entry(destination, 0, func() error {
+		err := WriteDevice(destination, j.mode(f), major, minor)
+		if errors.Is(err, os.ErrPermission) || errors.Is(err, errUnsupported) {
+			j.warn("%s: skipped as it cannot be created: %v", j.name(destination), err)
+			return errSkipEntry
+		}
+		if err == nil {
+			err = j.restore(destination, f)
+		}
+		if err != nil {
+			return err
+		}
+		return j.chtimes(destination, f)
+	})
+}
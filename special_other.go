@@ -0,0 +1,10 @@
+//go:build !(linux || darwin)
+
+package extract
+
+import "os"
+
+// mknod reports that devices cannot be created on this platform.
+func mknod(path string, mode os.FileMode, major, minor int64) error {
+	return errUnsupported
+}
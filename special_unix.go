@@ -0,0 +1,25 @@
+//go:build linux || darwin
+
+package extract
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mknod creates the device node or named pipe described by mode.
+func mknod(path string, mode os.FileMode, major, minor int64) error {
+	perm := uint32(mode.Perm())
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return unix.Mkfifo(path, perm)
+	case mode&os.ModeCharDevice != 0:
+		perm |= unix.S_IFCHR
+	case mode&os.ModeDevice != 0:
+		perm |= unix.S_IFBLK
+	default:
+		return errUnsupported
+	}
+	return unix.Mknod(path, perm, int(unix.Mkdev(uint32(major), uint32(minor))))
+}
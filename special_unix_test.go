@@ -0,0 +1,120 @@
+//go:build linux || darwin
+
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestExtractDevices(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_devices")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "fifo", Typeflag: tar.TypeFifo, Mode: 0644})
+	tw.WriteHeader(&tar.Header{Name: "null", Typeflag: tar.TypeChar, Mode: 0666, Devmajor: 1, Devminor: 3})
+	tw.Close()
+
+	res, err := ExtractStreamContext(context.Background(), &buf, dest, Options{TopLevel: Never})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+
+	fi, err := os.Lstat(filepath.Join(dest, "fifo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("fifo: expected a named pipe but got %v", fi.Mode())
+	}
+
+	fi, err = os.Lstat(filepath.Join(dest, "null"))
+	switch {
+	case os.IsNotExist(err):
+		// Creating devices needs privileges, without which the entry
+		// is skipped with a warning.
+		if len(res.Warnings) != 1 || res.Entries != 1 {
+			t.Errorf("null: expected a warning for the skipped device but got %v", res.Warnings)
+		}
+	case err != nil:
+		t.Fatal(err)
+	case fi.Mode()&os.ModeCharDevice == 0:
+		t.Errorf("null: expected a character device but got %v", fi.Mode())
+	}
+}
+
+func TestWriteSparseFile(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_sparse")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	const size = 8 << 20
+	data := make([]byte, size)
+	copy(data[1<<20:], "data in the middle")
+	data[size-1] = 1
+
+	file := filepath.Join(dest, "sparse")
+	// The reader is wrapped so that it is read in small pieces.
+	err = WriteSparseFile(file, io.LimitReader(bytes.NewReader(data), size), 0644)
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+
+	got, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("sparse file does not hold the data written")
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && st.Blocks*512 >= size {
+		t.Errorf("expected the file to have holes but %d bytes are allocated", st.Blocks*512)
+	}
+
+	// A file ending in a hole still has its full size.
+	err = WriteSparseFile(file, bytes.NewReader(make([]byte, 3*sparseBlock+1)), 0644)
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	fi, err = os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 3*sparseBlock+1 {
+		t.Errorf("expected size %d but got %d", 3*sparseBlock+1, fi.Size())
+	}
+}
+
+func TestIsSparse(t *testing.T) {
+	for i, tc := range []struct {
+		header *tar.Header
+		sparse bool
+	}{
+		{header: &tar.Header{Typeflag: tar.TypeReg}},
+		{header: &tar.Header{Typeflag: tar.TypeGNUSparse}, sparse: true},
+		{header: &tar.Header{Typeflag: tar.TypeReg, PAXRecords: map[string]string{"GNU.sparse.major": "1"}}, sparse: true},
+	} {
+		if got := isSparse(File{Header: tc.header}); got != tc.sparse {
+			t.Errorf("[%d] expected %v but got %v", i, tc.sparse, got)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package extract
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Galzzly/extract/v2/internal/magic"
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/nwaples/rardecode"
+	"github.com/ulikunitz/xz"
+)
+
+// defaultStreamName names the output of an unnamed single-file stream.
+const defaultStreamName = "stream"
+
+/*
+	ExtractStream extracts the archive read from r into dest. The format
+	is detected from the content of the stream rather than from a file
+	name, and any compression layers (gzip, bzip2, xz, zstd) are removed
+	before the archive within is extracted.
+
+	The stream is read once, so when opts.TopLevel depends on the entries
+	of the archive, they are written to a staging directory within dest
+	and moved into place once they are all known. Zip archives require
+	random access, so are spooled to a temporary file in os.TempDir
+	first, which needs room for the whole archive. Set
+	opts.Limits.MaxBytes to bound it. If the stream is a compressed file
+	rather than an archive, it is written to dest using opts.Name with
+	the compression extensions removed.
+*/
+func ExtractStream(r io.Reader, dest string, opts Options) error {
+	_, err := ExtractStreamContext(context.Background(), r, dest, opts)
+	return err
+}
+
+/*
+	ExtractStreamContext is ExtractStream with a context, that stops the
+	extraction when cancelled. The outcome is also returned as an
+	ArchiveResult, with the archive named after opts.Name, or "stream"
+	when no name is given.
+*/
+func ExtractStreamContext(ctx context.Context, r io.Reader, dest string, opts Options) (*ArchiveResult, error) {
+	start := time.Now()
+	archive := opts.Name
+	if archive == "" {
+		archive = defaultStreamName
+	}
+	res := &ArchiveResult{Archive: archive, Destination: dest}
+	j := newJob(ctx, &opts, res)
+
+	// The format is only known once the stream is read, so the choice
+	// of top level directory is always left until the end.
+	j.pending = j.needsTops() || opts.TopLevel.kind == topLevelAlways
+	j.progress.ArchiveStart(archive, -1)
+	err := j.staged(dest, func(dest string) error {
+		res.Destination = dest
+		err := j.extractStream(r, dest)
+		if err == errSkipEntry {
+			err = nil
+		}
+		if derr := j.setDirTimes(); err == nil {
+			err = derr
+		}
+		return err
+	})
+	j.finishManifest()
+	res.Duration = time.Since(start)
+	res.Err = err
+	j.progress.ArchiveDone(archive, err)
+	return res, err
+}
+
+/*
+	extractStream detects the format of the stream r, removing any
+	compression layers, and extracts it into dest.
+*/
+func (j *job) extractStream(r io.Reader, dest string) (err error) {
+	opts := j.opts
+	l := int(atomic.LoadUint32(&readLimit))
+	name := defaultStreamName
+	if opts.Name != "" {
+		name = filepath.Base(opts.Name)
+	}
+
+	br := bufio.NewReaderSize(j.source(r), l)
+	var (
+		peeled []string
+		layers []io.Closer
+	)
+	defer func() {
+		for i := len(layers) - 1; i >= 0; i-- {
+			layers[i].Close()
+		}
+	}()
+
+	for {
+		h, err := br.Peek(l)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return fmt.Errorf("problem looking at the stream: %v", err)
+		}
+
+		var (
+			next io.Reader
+			ext  string
+		)
+		switch {
+		case magic.Gz(h, uint32(l)):
+			gzr, err := pgzip.NewReader(br)
+			if err != nil {
+				return fmt.Errorf("problem opening gzip stream: %v", err)
+			}
+			layers = append(layers, gzr)
+			next, ext = gzr, ".gz"
+		case magic.Bz2(h, uint32(l)):
+			bzr, err := bzip2.NewReader(br, nil)
+			if err != nil {
+				return fmt.Errorf("problem opening bzip2 stream: %v", err)
+			}
+			layers = append(layers, bzr)
+			next, ext = bzr, ".bz2"
+		case magic.Xz(h, uint32(l)):
+			xzr, err := xz.NewReader(br)
+			if err != nil {
+				return fmt.Errorf("problem opening xz stream: %v", err)
+			}
+			next, ext = xzr, ".xz"
+		case magic.Zstd(h, uint32(l)):
+			zr, err := zstd.NewReader(br)
+			if err != nil {
+				return fmt.Errorf("problem opening zstd stream: %v", err)
+			}
+			layers = append(layers, zr.IOReadCloser())
+			next, ext = zr, ".zst"
+		case magic.Tar(h, uint32(l)):
+			j.res.Format = streamFormat("tar", peeled)
+			return j.extractTarStream(br, dest)
+		case magic.Zip(h, uint32(l)):
+			j.res.Format = streamFormat("zip", peeled)
+			return j.extractZipStream(br, dest)
+		case magic.Rar(h, uint32(l)):
+			j.res.Format = streamFormat("rar", peeled)
+			return j.extractRarStream(br, dest)
+		default:
+			if len(peeled) == 0 {
+				return fmt.Errorf("unable to recognise format of stream: %s", name)
+			}
+			j.res.Format = streamFormat("", peeled)
+			j.single = true
+			f := File{FileInfo: streamInfo{name: name}, ReadCloser: ReadFakeCloser{br}}
+			if !j.selected(name, f) {
+				return nil
+			}
+			name, ok := j.remap(name)
+			if !ok {
+				return nil
+			}
+			out, err := j.resolve(dest, name)
+			if err != nil {
+				return err
+			}
+			return j.writeFile(out, f)
+		}
+
+		name = trimCompressionExt(name, ext)
+		br = bufio.NewReaderSize(next, l)
+		peeled = append(peeled, ext)
+	}
+}
+
+/*
+	extractTarStream extracts the tar archive read from r into
+	destination.
+*/
+func (j *job) extractTarStream(r io.Reader, destination string) (err error) {
+	t := NewTar()
+	err = t.Open(r)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	return t.extractAll(j, destination)
+}
+
+/*
+	extractZipStream spools the zip archive read from r to a temporary
+	file, as the central directory at the end of the archive is needed
+	before any entry can be read, then extracts it into destination. An
+	archive larger than Limits.MaxBytes is not spooled past the limit.
+*/
+func (j *job) extractZipStream(r io.Reader, destination string) (err error) {
+	tmp, err := ioutil.TempFile("", "extract-*.zip")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var src io.Reader = ctxReader{j.ctx, r}
+	max := j.opts.Limits.MaxBytes
+	if max > 0 {
+		src = io.LimitReader(src, max+1)
+	}
+	size, err := io.Copy(tmp, src)
+	if err != nil {
+		return fmt.Errorf("error spooling zip stream: %v", err)
+	}
+	if max > 0 && size > max {
+		return &LimitExceededError{Limit: "MaxBytes", Filename: j.res.Archive}
+	}
+
+	z := NewZip()
+	err = z.Open(tmp, size)
+	if err != nil {
+		return fmt.Errorf("error opening archive for reading: %v", err)
+	}
+	defer z.Close()
+
+	return z.extractAll(j, destination)
+}
+
+/*
+	extractRarStream extracts the single-volume rar archive read from r
+	into destination.
+*/
+func (j *job) extractRarStream(r io.Reader, destination string) (err error) {
+	rar := NewRar()
+	rar.rr, err = rardecode.NewReader(r, "")
+	if err != nil {
+		return fmt.Errorf("unable to open rar archive: %v", err)
+	}
+	defer rar.Close()
+
+	return rar.extractAll(j, destination)
+}
+
+/*
+	streamFormat names the format of a stream from the archive found
+	within it and the compression layers removed to reach it, outermost
+	first, such as "tar.gz".
+*/
+func streamFormat(archive string, peeled []string) string {
+	name := archive
+	for i := len(peeled) - 1; i >= 0; i-- {
+		name += peeled[i]
+	}
+	return strings.TrimPrefix(name, ".")
+}
+
+/*
+	trimCompressionExt removes the extension ext from name. Short forms
+	such as .tgz are replaced with .tar, so that the name continues to
+	describe what is left within the stream.
+*/
+func trimCompressionExt(name, ext string) string {
+	short := map[string]string{
+		".gz":  ".tgz",
+		".bz2": ".tbz2",
+		".xz":  ".txz",
+		".zst": ".tzst",
+	}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ext):
+		return name[:len(name)-len(ext)]
+	case strings.HasSuffix(lower, short[ext]):
+		return name[:len(name)-len(short[ext])] + ".tar"
+	}
+	return name
+}
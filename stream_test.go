@@ -0,0 +1,106 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractStream(t *testing.T) {
+	testParent, err := ioutil.TempDir("", "extract_stream")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(testParent)
+
+	for i, tc := range []struct {
+		file   string
+		name   string
+		expect string
+	}{
+		{file: "testdata/test.tar", expect: "test/0dmnf3/f2eeblv6"},
+		{file: "testdata/test.tar.gz", expect: "test/0dmnf3/f2eeblv6"},
+		{file: "testdata/test.zip", expect: "test/0dmnf3/f2eeblv6"},
+		{file: "testdata/test.rar", expect: "test/0dmnf3/f2eeblv6"},
+		{file: "testdata/test.gz", name: "test.txt.gz", expect: "test.txt"},
+		{file: "testdata/test.bz2", expect: defaultStreamName},
+	} {
+		in, err := os.Open(tc.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		destDir := filepath.Join(testParent, filepath.Base(tc.file))
+		err = ExtractStream(in, destDir, Options{Name: tc.name})
+		in.Close()
+		if err != nil {
+			t.Errorf("[%d] %s: expected no error but got %v", i, tc.file, err)
+			continue
+		}
+		if !FileExists(filepath.Join(destDir, tc.expect)) {
+			t.Errorf("[%d] %s: expected %s to be extracted", i, tc.file, tc.expect)
+		}
+	}
+}
+
+func TestExtractStreamUnrecognised(t *testing.T) {
+	in, err := os.Open("testdata/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	testParent, err := ioutil.TempDir("", "extract_stream")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(testParent)
+
+	if err := ExtractStream(in, testParent, Options{}); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+func TestTrimCompressionExt(t *testing.T) {
+	for i, tc := range []struct {
+		name, ext, expect string
+	}{
+		{name: "a.tar.gz", ext: ".gz", expect: "a.tar"},
+		{name: "a.tgz", ext: ".gz", expect: "a.tar"},
+		{name: "a.TBZ2", ext: ".bz2", expect: "a.tar"},
+		{name: "a.txt.xz", ext: ".xz", expect: "a.txt"},
+		{name: "a", ext: ".zst", expect: "a"},
+	} {
+		if actual := trimCompressionExt(tc.name, tc.ext); actual != tc.expect {
+			t.Errorf("Test %d: expected %s but got %s", i, tc.expect, actual)
+		}
+	}
+}
+
+// zeros is an endless stream of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+func TestZipStreamLimit(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_stream")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	// The zip magic, followed by more than any limit.
+	in := io.MultiReader(bytes.NewReader([]byte("PK\x03\x04")), zeros{})
+	_, err = ExtractStreamContext(context.Background(), in, dest, Options{Limits: Limits{MaxBytes: 1 << 20}})
+	if !IsLimitExceededError(err) {
+		t.Errorf("expected LimitExceededError but got %v", err)
+	}
+}
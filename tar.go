@@ -5,12 +5,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"sync/atomic"
-	"time"
 
 	"github.com/Galzzly/extract/v2/internal/magic"
-	"github.com/vbauerster/mpb/v7"
 )
 
 type Tar struct {
@@ -27,114 +23,124 @@ type Tar struct {
 	the function will not return any error.
 */
 func (*Tar) CheckFormat(filename string) error {
-	l := atomic.LoadUint32(&readLimit)
-
-	// Get the header of filename
-	h, err := GetFileHeader(filename, l)
+	ok, err := detectFile(filename, isTar)
 	if err != nil {
 		return fmt.Errorf("problem looking at %s", filename)
 	}
-	mu.Lock()
-	defer mu.Unlock()
-
-	// Check if the file is a Tar file
-	var m = newMime("Tar", magic.Tar)
-	if !m.detector(h, l) {
+	if !ok {
 		return fmt.Errorf("%s is not a .tar file", filename)
 	}
 	return nil
 }
 
+// isTar reports whether s is the start of a tar archive.
+func isTar(s *Sniff) bool {
+	return magic.Tar(s.Head, s.limit)
+}
+
 /*
 	Extract will extract the file sent to the function
 */
-func (t *Tar) Extract(filename, destination string, p *mpb.Progress, start time.Time) (err error) {
-	b := AddNewBar(p, filename, start)
-	destination, err = t.topLevelDir(filename, destination)
+func (t *Tar) Extract(filename, destination string, p ProgressReporter) (err error) {
+	return extractArchive(t, filename, destination, p)
+}
+
+func (t *Tar) extract(j *job, filename, destination string) (err error) {
+	destination, err = t.topLevelDir(j, filename, destination)
 	if err != nil {
-		b.Abort(true)
-		return
+		return err
 	}
+	j.res.Destination = destination
 
 	f, err := os.Open(filename)
 	if err != nil {
-		b.Abort(true)
 		return fmt.Errorf("problems opening the tar archive %s: %v", filename, err)
 	}
 	defer f.Close()
 
-	err = t.Open(f)
+	err = t.Open(j.source(f))
 	if err != nil {
-		b.Abort(true)
 		return err
 	}
-	for {
+	defer t.Close()
 
-		err = t.untarNextFile(destination)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			b.Abort(true)
-			return fmt.Errorf("problem extracting file: %v", err)
-		}
-	}
-	b.SetTotal(1, true)
-	return nil
+	return t.extractAll(j, destination)
 }
 
 /*
-	topLevelDir will evaluate contents of the Rar file and checks for
-	a common root directory. If the root directory is found, the
-	destination will be modified to be relative to the root directory.
+	topLevelDir returns the directory within destination that the
+	archive filename is extracted into. When that depends on the top
+	level entries, their headers are read in a first pass, so that the
+	entries can then be written directly into place. The data of an
+	archive that is not compressed is seeked past, while a compressed
+	archive is decompressed in full. The destination of a deferred
+	archive is left as it is, to be chosen once extracted.
 */
-func (t *Tar) topLevelDir(source, destination string) (string, error) {
-	f, err := os.Open(source)
-	if err != nil {
-		return "", fmt.Errorf("error opening %s: %v", source, err)
+func (t *Tar) topLevelDir(j *job, filename, destination string) (string, error) {
+	if j.pending {
+		return destination, nil
 	}
-	defer f.Close()
-
-	// Open the Reader
-	r := io.Reader(f)
-	if t.readerWrapFn != nil {
-		r, err = t.readerWrapFn(r)
-		if err != nil {
-			return "", fmt.Errorf("problem with the wrapping reader: %v", err)
+	if j.needsTops() {
+		if err := t.readTops(j, filename); err != nil {
+			return "", err
 		}
 	}
-	if t.cleanupWrapFn != nil {
-		defer t.cleanupWrapFn()
+	return j.topLevel(destination)
+}
+
+// readTops records the top level elements of the entries of filename.
+func (t *Tar) readTops(j *job, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("problems opening the tar archive %s: %v", filename, err)
 	}
+	defer f.Close()
 
-	tr := tar.NewReader(r)
+	err = t.Open(f)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
 
-	// Get the files in the Tar archive
-	var files []string
 	for {
-		f, err := tr.Next()
+		if err := j.err(); err != nil {
+			return err
+		}
+		file, err := t.Read()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			mpb.BarFillerOnComplete("")
-			return "", fmt.Errorf("issue scanning tar file listings: %v", err)
+			return fmt.Errorf("problem reading the tar archive headers: %w", err)
+		}
+		h := file.Header.(*tar.Header)
+		if h.Typeflag == tar.TypeXGlobalHeader || !j.selected(h.Name, file) {
+			continue
+		}
+		if name, ok := j.remap(h.Name); ok {
+			j.addTop(name)
 		}
-		files = append(files, f.Name)
 	}
+}
 
-	if TopLevels(files) {
-		destination = filepath.Join(destination, DirFromFile(source))
+/*
+	extractAll will extract every remaining file in the open archive
+*/
+func (t *Tar) extractAll(j *job, destination string) error {
+	err := j.extractFrom(func() error {
+		return t.untarNextFile(j, destination)
+	})
+	if err != nil {
+		return fmt.Errorf("problem extracting file: %w", err)
 	}
-
-	return destination, nil
+	return nil
 }
 
 /*
 	untarNextFile will read the next file in the Rar archive, check the path
 	and move on to perform the extraction via untarFile
 */
-func (t *Tar) untarNextFile(destination string) (err error) {
+func (t *Tar) untarNextFile(j *job, destination string) (err error) {
 	f, err := t.Read()
 	if err != nil {
 		return
@@ -144,32 +150,46 @@ func (t *Tar) untarNextFile(destination string) (err error) {
 	if !ok {
 		return fmt.Errorf("expected header to be *tar.Header but found %T", f.Header)
 	}
+	// Global headers hold no file, so neither count towards the top
+	// level entries nor are extracted.
+	if h.Typeflag == tar.TypeXGlobalHeader || !j.selected(h.Name, f) {
+		return nil
+	}
+	name, ok := j.remap(h.Name)
+	if !ok {
+		return nil
+	}
 
-	err = CheckPath(destination, h.Name)
+	dest, err := j.resolve(destination, name)
 	if err != nil {
-		return fmt.Errorf("checking path: %v", err)
+		return
 	}
 
-	return t.untarFile(f, destination, h)
+	return t.untarFile(j, f, dest, h)
 }
 
 /*
-	untarFile will extract the file sent to the function
+	untarFile will extract the file sent to the function to dest, the
+	path already resolved within the destination
 */
-func (t *Tar) untarFile(f File, destination string, h *tar.Header) (err error) {
-	dest := filepath.Join(destination, h.Name)
-
+func (t *Tar) untarFile(j *job, f File, dest string, h *tar.Header) (err error) {
 	switch h.Typeflag {
 	case tar.TypeDir:
-		return Mkdir(dest, f.Mode())
-	case tar.TypeReg, tar.TypeRegA, tar.TypeBlock, tar.TypeFifo, tar.TypeGNUSparse:
-		return WriteFile(dest, f, f.Mode())
+		return j.mkdir(dest, f)
+	case tar.TypeReg, tar.TypeRegA, tar.TypeGNUSparse:
+		return j.writeFile(dest, f)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		return j.device(dest, f, h.Devmajor, h.Devminor)
 	case tar.TypeSymlink:
-		return WriteSymlink(dest, h.Linkname)
+		return j.symlink(dest, h.Linkname, f)
 	case tar.TypeLink:
-		return WriteHardlink(dest, h.Linkname)
-	case tar.TypeXGlobalHeader:
-		return nil
+		// The target names another entry, so is remapped in the same way.
+		link, ok := j.remap(h.Linkname)
+		if !ok {
+			j.warn("%s: skipped as the target of the link, %s, is not extracted", h.Name, h.Linkname)
+			return nil
+		}
+		return j.hardlink(dest, link, f)
 	default:
 		return fmt.Errorf("%s: unknown type flag: %c", h.Name, h.Typeflag)
 	}
@@ -217,7 +237,7 @@ func (t *Tar) Read() (f File, err error) {
 	Close will close the tar archive
 */
 func (t *Tar) Close() {
-	if t.tr == nil {
+	if t.tr != nil {
 		t.tr = nil
 	}
 	if t.cleanupWrapFn != nil {
@@ -225,6 +245,11 @@ func (t *Tar) Close() {
 	}
 }
 
+func init() {
+	RegisterFormat("tar", isTar, func() Extractor { return NewTar() }, 0)
+	RegisterFallbackExtensions("tar", ".tar")
+}
+
 func NewTar() *Tar {
 	return &Tar{
 		MkdirAll: true,
@@ -0,0 +1,87 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Galzzly/extract/v2/internal/magic"
+	"github.com/dsnet/compress/bzip2"
+)
+
+// TarBz2 compresses a tar archive with bzip2
+type TarBz2 struct {
+	*Tar
+	CompressionLevel int
+}
+
+/*
+	CheckFormat will check the file sent to the function
+	against magic numbers for Bzip2 & Tar. If the file is a TarBz2
+	the function will not return any error.
+*/
+func (*TarBz2) CheckFormat(filename string) error {
+	ok, err := detectFile(filename, isTarBz2)
+	if err != nil {
+		return fmt.Errorf("problem looking at %s", filename)
+	}
+	if !ok {
+		return fmt.Errorf("%s is not a tar.bz2 file", filename)
+	}
+	return nil
+}
+
+/*
+	isTarBz2 reports whether s is the start of a bzip2 file holding a
+	tar archive.
+*/
+func isTarBz2(s *Sniff) bool {
+	return s.Compression == "bz2" && magic.Tar(s.Inner, s.limit)
+}
+
+/*
+	Extract will extract the file sent to the function
+*/
+func (tbz *TarBz2) Extract(filename, destination string, p ProgressReporter) (err error) {
+	return extractArchive(tbz, filename, destination, p)
+}
+
+func (tbz *TarBz2) extract(j *job, filename, destination string) (err error) {
+	tbz.wrapReader()
+	return tbz.Tar.extract(j, filename, destination)
+}
+
+/*
+	Open will set the Reader in the underlying tar archive
+	then open the archive
+*/
+func (tbz *TarBz2) Open(in io.Reader) (err error) {
+	tbz.wrapReader()
+	return tbz.Tar.Open(in)
+}
+
+/*
+	wrapReader will wrap the Reader in a bzip2 reader
+*/
+func (tbz *TarBz2) wrapReader() {
+	var bzr io.ReadCloser
+	tbz.Tar.readerWrapFn = func(r io.Reader) (io.Reader, error) {
+		var err error
+		bzr, err = bzip2.NewReader(r, nil)
+		return bzr, err
+	}
+	tbz.Tar.cleanupWrapFn = func() {
+		bzr.Close()
+	}
+}
+
+func init() {
+	RegisterFormat("tar.bz2", isTarBz2, func() Extractor { return NewTarBz2() }, 10)
+	RegisterExtensions("tar.bz2", ".tar.bz2", ".tbz2", ".tbz")
+}
+
+func NewTarBz2() *TarBz2 {
+	return &TarBz2{
+		CompressionLevel: bzip2.DefaultCompression,
+		Tar:              NewTar(),
+	}
+}
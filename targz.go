@@ -4,13 +4,9 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
-	"os"
-	"sync/atomic"
-	"time"
 
 	"github.com/Galzzly/extract/v2/internal/magic"
 	"github.com/klauspost/pgzip"
-	"github.com/vbauerster/mpb/v7"
 )
 
 // TarGz compresses a tar archive
@@ -28,57 +24,35 @@ type TarGz struct {
 	for a GZip file, and if so, will check that the file within
 	contains the magic number for a Tar file.
 */
-func (tgz *TarGz) CheckFormat(filename string) (err error) {
-	l := atomic.LoadUint32(&readLimit)
-
-	// Get the header of the GZip file
-	gh, err := GetFileHeader(filename, l)
+func (*TarGz) CheckFormat(filename string) error {
+	ok, err := detectFile(filename, isTarGz)
 	if err != nil {
 		return fmt.Errorf("problem looking at %s", filename)
 	}
-	mu.Lock()
-	defer mu.Unlock()
-
-	// Check if the file is a GZip file
-	var gm = newMime("Gzip", magic.Gz)
-	if !gm.detector(gh, l) {
-		return fmt.Errorf("%s is not a gzip bundle", filename)
-	}
-
-	// Open the Gzip file for reading
-	f, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("problem opening %s", filename)
+	if !ok {
+		return fmt.Errorf("%s is not a tar.gz file", filename)
 	}
-	defer f.Close()
-
-	// Create the pgzip reader
-	r, _ := pgzip.NewReader(f)
-	defer r.Close()
-	if err != nil {
-		return fmt.Errorf("problem opening %s", filename)
-	}
-	// Using the pgzip reader, get the header of the tar file
-	th, err := GetHeader(r, l)
-	if err != nil {
-		return fmt.Errorf("problem looking at the underlying file in %s", filename)
-	}
-
-	// Check if the file within the GZip file is a tar file
-	var tm = newMime("Tar", magic.Tar)
-	if !tm.detector(th, l) {
-		return fmt.Errorf("%s is not a tar file", filename)
-	}
-
 	return nil
 }
 
+/*
+	isTarGz reports whether s is the start of a gzip file holding a tar
+	archive.
+*/
+func isTarGz(s *Sniff) bool {
+	return s.Compression == "gz" && magic.Tar(s.Inner, s.limit)
+}
+
 /*
 	Extract will extract the file sent to the function
 */
-func (tgz *TarGz) Extract(filename, destination string, p *mpb.Progress, start time.Time) (err error) {
+func (tgz *TarGz) Extract(filename, destination string, p ProgressReporter) (err error) {
+	return extractArchive(tgz, filename, destination, p)
+}
+
+func (tgz *TarGz) extract(j *job, filename, destination string) (err error) {
 	tgz.wrapReader()
-	return tgz.Tar.Extract(filename, destination, p, start)
+	return tgz.Tar.extract(j, filename, destination)
 }
 
 /*
@@ -105,6 +79,11 @@ func (tgz *TarGz) wrapReader() {
 	}
 }
 
+func init() {
+	RegisterFormat("tar.gz", isTarGz, func() Extractor { return NewTarGz() }, 10)
+	RegisterExtensions("tar.gz", ".tar.gz", ".tgz")
+}
+
 func NewTarGz() *TarGz {
 	return &TarGz{
 		CompressionLevel: gzip.DefaultCompression,
@@ -0,0 +1,79 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Galzzly/extract/v2/internal/magic"
+	"github.com/ulikunitz/xz"
+)
+
+// TarXz compresses a tar archive with xz
+type TarXz struct {
+	*Tar
+}
+
+/*
+	CheckFormat will check the file sent to the function
+	against magic numbers for Xz & Tar. If the file is a TarXz
+	the function will not return any error.
+*/
+func (*TarXz) CheckFormat(filename string) error {
+	ok, err := detectFile(filename, isTarXz)
+	if err != nil {
+		return fmt.Errorf("problem looking at %s", filename)
+	}
+	if !ok {
+		return fmt.Errorf("%s is not a tar.xz file", filename)
+	}
+	return nil
+}
+
+/*
+	isTarXz reports whether s is the start of an xz file holding a
+	tar archive.
+*/
+func isTarXz(s *Sniff) bool {
+	return s.Compression == "xz" && magic.Tar(s.Inner, s.limit)
+}
+
+/*
+	Extract will extract the file sent to the function
+*/
+func (txz *TarXz) Extract(filename, destination string, p ProgressReporter) (err error) {
+	return extractArchive(txz, filename, destination, p)
+}
+
+func (txz *TarXz) extract(j *job, filename, destination string) (err error) {
+	txz.wrapReader()
+	return txz.Tar.extract(j, filename, destination)
+}
+
+/*
+	Open will set the Reader in the underlying tar archive
+	then open the archive
+*/
+func (txz *TarXz) Open(in io.Reader) (err error) {
+	txz.wrapReader()
+	return txz.Tar.Open(in)
+}
+
+/*
+	wrapReader will wrap the Reader in an xz reader
+*/
+func (txz *TarXz) wrapReader() {
+	txz.Tar.readerWrapFn = func(r io.Reader) (io.Reader, error) {
+		return xz.NewReader(r)
+	}
+}
+
+func init() {
+	RegisterFormat("tar.xz", isTarXz, func() Extractor { return NewTarXz() }, 10)
+	RegisterExtensions("tar.xz", ".tar.xz", ".txz")
+}
+
+func NewTarXz() *TarXz {
+	return &TarXz{
+		Tar: NewTar(),
+	}
+}
@@ -0,0 +1,180 @@
+package extract
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zip"
+	"github.com/nwaples/rardecode"
+)
+
+// The ids of the zip extra fields that hold timestamps.
+const (
+	zipNTFSExtraID        = 0x000a
+	zipUnixExtraID        = 0x000d
+	zipExtTimeExtraID     = 0x5455
+	zipInfoZipUnixExtraID = 0x5855
+)
+
+/*
+	dirTime holds the times of a directory, which are set once all of
+	its children have been written.
+*/
+type dirTime struct {
+	path         string
+	mtime, atime time.Time
+}
+
+/*
+	entryTimes returns the modification and access times recorded for f
+	by its archive. The access time is the modification time when the
+	archive does not record one, and both are zero when neither is.
+*/
+func entryTimes(f File) (mtime, atime time.Time) {
+	switch h := f.Header.(type) {
+	case *tar.Header:
+		mtime, atime = h.ModTime, h.AccessTime
+	case zip.FileHeader:
+		mtime, atime = h.Modified, zipAccessTime(h.Extra)
+	case *rardecode.FileHeader:
+		mtime, atime = h.ModificationTime, h.AccessTime
+	default:
+		mtime = f.ModTime()
+	}
+	if atime.IsZero() {
+		atime = mtime
+	}
+	return mtime, atime
+}
+
+/*
+	zipAccessTime returns the access time held in the extra fields of a
+	zip entry, from the NTFS, extended timestamp or Unix fields.
+	The modification time held in the same fields is read by the zip
+	package into FileHeader.Modified.
+*/
+func zipAccessTime(extra []byte) (atime time.Time) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		field := extra[:size]
+		extra = extra[size:]
+
+		switch id {
+		case zipNTFSExtraID:
+			// A reserved field, then attributes of a tag and size. Tag 1
+			// holds the modification, access and creation times.
+			if len(field) < 4 {
+				continue
+			}
+			for attrs := field[4:]; len(attrs) >= 4; {
+				tag := binary.LittleEndian.Uint16(attrs)
+				n := int(binary.LittleEndian.Uint16(attrs[2:]))
+				attrs = attrs[4:]
+				if n > len(attrs) {
+					break
+				}
+				if tag == 1 && n == 24 {
+					atime = ntfsTime(binary.LittleEndian.Uint64(attrs[8:]))
+				}
+				attrs = attrs[n:]
+			}
+		case zipExtTimeExtraID:
+			// Flags, then each of the times that the flags mark as set.
+			// The central directory usually holds only the first.
+			if len(field) < 1 || field[0]&2 == 0 {
+				continue
+			}
+			off := 1
+			if field[0]&1 != 0 {
+				off += 4
+			}
+			if len(field) >= off+4 {
+				atime = time.Unix(int64(binary.LittleEndian.Uint32(field[off:])), 0)
+			}
+		case zipUnixExtraID, zipInfoZipUnixExtraID:
+			if len(field) >= 8 {
+				atime = time.Unix(int64(binary.LittleEndian.Uint32(field)), 0)
+			}
+		}
+	}
+	return atime
+}
+
+// ntfsTime converts a Windows timestamp, in 100ns ticks since 1601.
+func ntfsTime(ticks uint64) time.Time {
+	const ticksPerSecond = 1e7
+	epoch := time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return time.Unix(epoch.Unix()+int64(ticks/ticksPerSecond), int64(ticks%ticksPerSecond)*100)
+}
+
+/*
+	chtimes sets the times of the entry f written to destination,
+	without following a symlink. Directories are recorded to be set
+	once the extraction is complete, as writing their children would
+	change them.
+*/
+func (j *job) chtimes(destination string, f File) error {
+	mtime, atime := entryTimes(f)
+	if mtime.IsZero() {
+		return nil
+	}
+
+	switch {
+	case f.IsDir():
+		j.dirs = append(j.dirs, dirTime{destination, mtime, atime})
+		return nil
+	case f.Mode()&os.ModeSymlink != 0:
+		err := lchtimes(destination, atime, mtime)
+		if err != nil {
+			return fmt.Errorf("%s: error setting times: %v", destination, err)
+		}
+		return nil
+	}
+
+	err := os.Chtimes(destination, atime, mtime)
+	if err != nil {
+		return fmt.Errorf("%s: error setting times: %v", destination, err)
+	}
+	return nil
+}
+
+// realDir returns an error unless path is a directory, not a symlink to one.
+func realDir(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s: not a directory", path)
+	}
+	return nil
+}
+
+/*
+	setDirTimes sets the times of the directories extracted, deepest
+	first, once all of the entries have been written. Those that have
+	since been replaced by anything other than a directory are left.
+*/
+func (j *job) setDirTimes() error {
+	var first error
+	for i := len(j.dirs) - 1; i >= 0; i-- {
+		d := j.dirs[i]
+		if realDir(d.path) != nil {
+			continue
+		}
+		err := os.Chtimes(d.path, d.atime, d.mtime)
+		if err != nil && first == nil {
+			first = fmt.Errorf("%s: error setting times: %v", d.path, err)
+		}
+	}
+	j.dirs = nil
+	return first
+}
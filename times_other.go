@@ -0,0 +1,11 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package extract
+
+import "time"
+
+// lchtimes leaves the times of symlinks as they are created on
+// platforms without a way to set them.
+func lchtimes(path string, atime, mtime time.Time) error {
+	return nil
+}
@@ -0,0 +1,119 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExtractTimes(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_times")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	dirTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	fileTime := time.Date(2002, 3, 4, 5, 6, 7, 0, time.UTC)
+	linkTime := time.Date(2003, 4, 5, 6, 7, 8, 0, time.UTC)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: dirTime})
+	tw.WriteHeader(&tar.Header{Name: "dir/file", Typeflag: tar.TypeReg, Mode: 0644, Size: 1, ModTime: fileTime})
+	tw.Write([]byte("x"))
+	tw.WriteHeader(&tar.Header{Name: "dir/link", Typeflag: tar.TypeSymlink, Linkname: "file", ModTime: linkTime})
+	tw.Close()
+
+	if _, err := ExtractStreamContext(context.Background(), &buf, dest, Options{}); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+
+	for name, want := range map[string]time.Time{
+		"dir":      dirTime,
+		"dir/file": fileTime,
+		"dir/link": linkTime,
+	} {
+		fi, err := os.Lstat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fi.ModTime(); !got.Equal(want) {
+			t.Errorf("%s: expected modification time %v but got %v", name, want, got)
+		}
+	}
+}
+
+func TestExtractTimesFormats(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_times")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	for _, file := range []string{"testdata/test.tar", "testdata/test.zip", "testdata/test.rar"} {
+		dest := filepath.Join(parent, filepath.Base(file))
+		if _, err := ExtractContext(context.Background(), []string{file}, Options{Destination: dest}); err != nil {
+			t.Fatalf("%s: expected no error but got %v", file, err)
+		}
+		fi, err := os.Stat(filepath.Join(dest, "test/0dmnf3/f2eeblv6"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Since(fi.ModTime()) < time.Hour {
+			t.Errorf("%s: expected the modification time from the archive but got %v", file, fi.ModTime())
+		}
+	}
+}
+
+func TestZipAccessTime(t *testing.T) {
+	atime := time.Date(2004, 5, 6, 7, 8, 9, 0, time.UTC)
+	field := func(id uint16, data []byte) []byte {
+		b := make([]byte, 4, 4+len(data))
+		binary.LittleEndian.PutUint16(b, id)
+		binary.LittleEndian.PutUint16(b[2:], uint16(len(data)))
+		return append(b, data...)
+	}
+	u32 := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, v)
+		return b
+	}
+	u64 := func(v uint64) []byte {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, v)
+		return b
+	}
+	// Seconds between the Windows epoch of 1601 and the Unix epoch.
+	ticks := uint64(atime.Unix()+11644473600) * 1e7
+
+	var ntfs []byte
+	ntfs = append(ntfs, u32(0)...)
+	ntfs = append(ntfs, 1, 0, 24, 0)
+	ntfs = append(ntfs, u64(0)...)
+	ntfs = append(ntfs, u64(ticks)...)
+	ntfs = append(ntfs, u64(0)...)
+
+	ext := append([]byte{3}, u32(0)...)
+	ext = append(ext, u32(uint32(atime.Unix()))...)
+
+	for i, extra := range [][]byte{
+		field(zipNTFSExtraID, ntfs),
+		field(zipExtTimeExtraID, ext),
+		field(zipInfoZipUnixExtraID, append(u32(uint32(atime.Unix())), u32(0)...)),
+		append(field(0xcafe, []byte{1, 2}), field(zipUnixExtraID, append(u32(uint32(atime.Unix())), u32(0)...))...),
+	} {
+		if got := zipAccessTime(extra); !got.Equal(atime) {
+			t.Errorf("[%d] expected %v but got %v", i, atime, got)
+		}
+	}
+	if got := zipAccessTime(field(zipExtTimeExtraID, append([]byte{1}, u32(0)...))); !got.IsZero() {
+		t.Errorf("expected no access time but got %v", got)
+	}
+}
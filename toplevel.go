@@ -0,0 +1,208 @@
+package extract
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+/*
+	TopLevelPolicy controls whether an archive is extracted into a
+	directory of its own within the destination, named after the
+	archive by DirFromFile. Compressed files that hold a single file
+	rather than an archive are always written directly.
+
+	Under Auto, or a policy from Custom, the entries of streams
+	and of archives filtered with Filter.Match are only known once they
+	are read, so are extracted into a hidden ".extract-" staging
+	directory within the destination and then moved into place, as with
+	Options.Atomic. The destination must then be writable and on one
+	file system with its contents, and a process that is killed part
+	way through leaves the staging directory behind. Never and Always
+	write the entries directly.
+*/
+type TopLevelPolicy struct {
+	kind topLevelKind
+	dir  func(archive string, tops []string) string
+}
+
+type topLevelKind int
+
+const (
+	topLevelAuto topLevelKind = iota
+	topLevelAlways
+	topLevelNever
+	topLevelCustom
+)
+
+var (
+	/*
+		Auto extracts archives with more than one top level entry into
+		a directory of their own, so that they do not spill across the
+		destination. It is the default policy. Archives whose entries
+		are remapped with StripComponents or Rename are not wrapped.
+
+		The top level entries of zip archives are read from their
+		central directory, and those of tar and rar archives from a
+		first pass over their headers, so that the entries are written
+		directly; compressed tar archives are decompressed twice.
+		Streams, and archives filtered with Filter.Match, are instead
+		staged as described by TopLevelPolicy.
+	*/
+	Auto = TopLevelPolicy{kind: topLevelAuto}
+
+	// Always extracts every archive into a directory of its own.
+	Always = TopLevelPolicy{kind: topLevelAlways}
+
+	// Never extracts the entries directly into the destination.
+	Never = TopLevelPolicy{kind: topLevelNever}
+)
+
+/*
+	Custom returns a policy that calls dir with the name of the archive
+	and its distinct top level entries, sorted, once they are known. It
+	returns the directory within the destination to extract into, or ""
+	for the destination itself.
+*/
+func Custom(dir func(archive string, tops []string) string) TopLevelPolicy {
+	return TopLevelPolicy{kind: topLevelCustom, dir: dir}
+}
+
+var topLevelPolicies = map[topLevelKind]string{
+	topLevelAuto:   "auto",
+	topLevelAlways: "always",
+	topLevelNever:  "never",
+	topLevelCustom: "custom",
+}
+
+func (p TopLevelPolicy) String() string {
+	if s, ok := topLevelPolicies[p.kind]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+/*
+	ParseTopLevelPolicy returns the TopLevelPolicy named by s, one of
+	"auto", "always" or "never".
+*/
+func ParseTopLevelPolicy(s string) (TopLevelPolicy, error) {
+	for _, p := range []TopLevelPolicy{Auto, Always, Never} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return Auto, fmt.Errorf("unknown top level policy: %s", s)
+}
+
+/*
+	needsTops reports whether the top level directory depends on the
+	entries of the archive, rather than on the policy alone.
+*/
+func (j *job) needsTops() bool {
+	switch j.opts.TopLevel.kind {
+	case topLevelAuto:
+		return !j.remaps()
+	case topLevelCustom:
+		return true
+	}
+	return false
+}
+
+/*
+	prepare returns the destination that the archive extracted by x is
+	extracted into, when it can be chosen up front, or marks the job as
+	pending until the entries are known. Zip archives list their entries
+	in the central directory, and tar and rar archives are read twice,
+	so choose their own, unless Filter.Match is set as it is only called
+	once for each entry. Compressed files hold a single entry, so are
+	never wrapped. The entries of other archives are only known once
+	they have been read, so are written to a staging directory that is
+	moved into place at the end.
+*/
+func (j *job) prepare(x archiveExtractor, destination string) (string, error) {
+	switch x.(type) {
+	case *Gz, *Bz2:
+		return destination, nil
+	case *Zip, *Tar, *TarGz, *TarBz2, *TarXz, *Rar:
+		if j.opts.Filter.Match == nil || !j.needsTops() {
+			return destination, nil
+		}
+	}
+	if j.needsTops() {
+		j.pending = true
+		return destination, nil
+	}
+	return j.topLevel(destination)
+}
+
+// addTop records the top level element of the entry name.
+func (j *job) addTop(name string) {
+	for _, p := range splitPath(name) {
+		if p == "." {
+			continue
+		}
+		if j.tops == nil {
+			j.tops = make(map[string]bool)
+		}
+		j.tops[p] = true
+		return
+	}
+}
+
+/*
+	topLevel returns the directory within destination that the archive
+	is extracted into, from the policy and the top level entries
+	recorded so far.
+*/
+func (j *job) topLevel(destination string) (string, error) {
+	if j.single {
+		return destination, nil
+	}
+	p := j.opts.TopLevel
+	var dir string
+	switch p.kind {
+	case topLevelAuto:
+		if !j.remaps() && len(j.tops) > 1 {
+			dir = DirFromFile(j.res.Archive)
+		}
+	case topLevelAlways:
+		dir = DirFromFile(j.res.Archive)
+	case topLevelCustom:
+		if p.dir != nil {
+			tops := make([]string, 0, len(j.tops))
+			for t := range j.tops {
+				tops = append(tops, t)
+			}
+			sort.Strings(tops)
+			dir = p.dir(j.res.Archive, tops)
+		}
+	}
+	if dir == "" {
+		return destination, nil
+	}
+
+	dest, err := SecurePath(destination, dir)
+	if err != nil {
+		return "", fmt.Errorf("checking top level directory: %w", err)
+	}
+	return dest, nil
+}
+
+/*
+	settle applies the overwrite policy to a staged entry, modified at
+	modTime, as it is moved to destination. It is used for the entries
+	of a deferred archive, which could not be checked as they were
+	written as where they would be moved to was not yet known.
+*/
+func (j *job) settle(destination string, modTime time.Time) (string, bool, error) {
+	to, ok, err := j.conflict(destination, modTime)
+	switch {
+	case !ok && err == nil:
+		j.res.Entries--
+		j.moveChecksum(destination, "")
+	case ok && to != destination:
+		j.moveChecksum(destination, to)
+	}
+	return to, ok, err
+}
@@ -0,0 +1,228 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDirFromFile(t *testing.T) {
+	for i, tc := range []struct {
+		filename string
+		expect   string
+	}{
+		{filename: "app-1.2.3.tar.gz", expect: "app-1.2.3"},
+		{filename: "/tmp/app-1.2.3.TGZ", expect: "app-1.2.3"},
+		{filename: "data.tar.zst", expect: "data"},
+		{filename: "backup.2020.tar", expect: "backup.2020"},
+		{filename: "site.zip", expect: "site"},
+		{filename: "set.part01.rar", expect: "set"},
+		{filename: "notes.txt.bz2", expect: "notes.txt"},
+		{filename: "bundle.7z", expect: "bundle"},
+		{filename: "bundle", expect: "bundle"},
+		{filename: ".tar", expect: ".tar"},
+	} {
+		if got := DirFromFile(tc.filename); got != tc.expect {
+			t.Errorf("[%d] %s: expected %q but got %q", i, tc.filename, tc.expect, got)
+		}
+	}
+}
+
+func TestParseTopLevelPolicy(t *testing.T) {
+	for _, p := range []TopLevelPolicy{Auto, Always, Never} {
+		got, err := ParseTopLevelPolicy(p.String())
+		if err != nil || got.String() != p.String() {
+			t.Errorf("%v: expected the policy back but got %v (%v)", p, got, err)
+		}
+	}
+	if _, err := ParseTopLevelPolicy("custom"); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+func TestExtractTopLevel(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_toplevel")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	// An archive with two top level entries, named with a version.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "a/x", Typeflag: tar.TypeReg, Mode: 0644, Size: 1})
+	tw.Write([]byte("x"))
+	tw.WriteHeader(&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.Close()
+	archive := filepath.Join(parent, "app-1.2.3.tar")
+	if err := ioutil.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A single top level entry after a global header, as git archive
+	// writes.
+	buf.Reset()
+	tw = tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": "abc"}})
+	tw.WriteHeader(&tar.Header{Name: "one/x", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.Close()
+	global := filepath.Join(parent, "one-1.0.tar")
+	if err := ioutil.WriteFile(global, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range []struct {
+		file   string
+		opts   Options
+		expect string
+	}{
+		{file: archive, expect: "app-1.2.3/a/x"},
+		{file: archive, opts: Options{Atomic: true}, expect: "app-1.2.3/a/x"},
+		{file: archive, opts: Options{TopLevel: Never}, expect: "a/x"},
+		{file: archive, opts: Options{StripComponents: 1}, expect: "x"},
+		{file: global, expect: "one/x"},
+		{file: "testdata/test.tar.gz", expect: "test/80nj"},
+		{file: "testdata/test.tar.gz", opts: Options{TopLevel: Always}, expect: "test/test/80nj"},
+		{file: "testdata/test.zip", opts: Options{TopLevel: Always}, expect: "test/test/80nj"},
+		{file: "testdata/test.rar", opts: Options{TopLevel: Always}, expect: "test/test/80nj"},
+	} {
+		dest := filepath.Join(parent, strconv.Itoa(i))
+		tc.opts.Destination = dest
+		_, err := ExtractContext(context.Background(), []string{tc.file}, tc.opts)
+		if err != nil {
+			t.Fatalf("[%d] %s: expected no error but got %v", i, tc.file, err)
+		}
+		if !FileExists(filepath.Join(dest, tc.expect)) {
+			t.Errorf("[%d] %s: expected %s to be extracted", i, tc.file, tc.expect)
+		}
+		if stagingLeft(t, dest) {
+			t.Errorf("[%d] %s: staging directory was left behind", i, tc.file)
+		}
+	}
+}
+
+func TestExtractTopLevelCustom(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_toplevel")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	for _, file := range []string{"testdata/test.tar.gz", "testdata/test.zip", "testdata/test.rar"} {
+		var got []string
+		dest := filepath.Join(parent, filepath.Base(file))
+		opts := Options{
+			Destination: dest,
+			TopLevel: Custom(func(archive string, tops []string) string {
+				got = tops
+				return "custom"
+			}),
+		}
+		res, err := ExtractContext(context.Background(), []string{file}, opts)
+		if err != nil {
+			t.Fatalf("%s: expected no error but got %v", file, err)
+		}
+		if !reflect.DeepEqual(got, []string{"test"}) {
+			t.Errorf("%s: expected the top level entries [test] but got %v", file, got)
+		}
+		if !FileExists(filepath.Join(dest, "custom/test/80nj")) {
+			t.Errorf("%s: expected custom/test/80nj to be extracted", file)
+		}
+		if want := filepath.Join(dest, "custom"); res.Archives[0].Destination != want {
+			t.Errorf("%s: expected destination %s but got %s", file, want, res.Archives[0].Destination)
+		}
+	}
+
+	opts := Options{TopLevel: Custom(func(string, []string) string { return "../escape" })}
+	in, err := os.Open("testdata/test.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	_, err = ExtractStreamContext(context.Background(), in, filepath.Join(parent, "stream"), opts)
+	if !IsIllegalPathError(err) {
+		t.Errorf("expected IllegalPathError but got %v", err)
+	}
+}
+
+func TestExtractDeferredOverwrite(t *testing.T) {
+	dest, err := ioutil.TempDir("", "extract_toplevel")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(dest)
+
+	existing := filepath.Join(dest, "test", "80nj")
+	if err := WriteFile(existing, bytes.NewBufferString("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []OverwritePolicy{Skip, Rename} {
+		res, err := ExtractContext(context.Background(), []string{"testdata/test.tar.gz"}, Options{Destination: dest, Overwrite: policy})
+		if err != nil {
+			t.Fatalf("%v: expected no error but got %v", policy, err)
+		}
+		if got, _ := ioutil.ReadFile(existing); string(got) != "old" {
+			t.Errorf("%v: expected existing file to be kept but it holds %q", policy, got)
+		}
+		if stagingLeft(t, dest) {
+			t.Errorf("%v: staging directory was left behind", policy)
+		}
+		switch policy {
+		case Skip:
+			if !reflect.DeepEqual(res.Archives[0].Skipped, []string{"test/80nj"}) {
+				t.Errorf("%v: expected test/80nj to be skipped but got %v", policy, res.Archives[0].Skipped)
+			}
+		case Rename:
+			if !FileExists(existing + ".1") {
+				t.Errorf("%v: expected the entry to be written to %s.1", policy, existing)
+			}
+		}
+	}
+}
+
+// stagingProgress records whether a staging directory is found in dest
+// while the entries of an archive are written.
+type stagingProgress struct {
+	NopProgress
+	t      *testing.T
+	dest   string
+	staged bool
+}
+
+func (p *stagingProgress) EntryDone(archive, name string, err error) {
+	if stagingLeft(p.t, p.dest) {
+		p.staged = true
+	}
+}
+
+func TestExtractTopLevelDirect(t *testing.T) {
+	parent, err := ioutil.TempDir("", "extract_toplevel")
+	if err != nil {
+		t.Fatalf("Error creating temporary dir")
+	}
+	defer os.RemoveAll(parent)
+
+	for i, file := range []string{"testdata/test.tar", "testdata/test.tar.gz", "testdata/test-v7.tar.bz2", "testdata/test.rar"} {
+		for _, top := range []TopLevelPolicy{Auto, Custom(func(string, []string) string { return "custom" })} {
+			dest := filepath.Join(parent, strconv.Itoa(i), top.String())
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				t.Fatal(err)
+			}
+			p := &stagingProgress{t: t, dest: dest}
+			_, err := ExtractContext(context.Background(), []string{file}, Options{Destination: dest, TopLevel: top, Progress: p})
+			if err != nil {
+				t.Fatalf("[%d] %s: expected no error but got %v", i, file, err)
+			}
+			if p.staged {
+				t.Errorf("[%d] %s (%v): expected the entries to be written directly", i, file, top)
+			}
+		}
+	}
+}
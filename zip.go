@@ -7,17 +7,13 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"strings"
-	"sync/atomic"
-	"time"
 
 	"github.com/Galzzly/extract/v2/internal/magic"
 	"github.com/dsnet/compress/bzip2"
 	"github.com/klauspost/compress/zip"
 	"github.com/klauspost/compress/zstd"
 	"github.com/ulikunitz/xz"
-	"github.com/vbauerster/mpb/v7"
 )
 
 // ZipCompressionMethod Compression type
@@ -37,6 +33,22 @@ const (
 	XZ      ZipCompressionMethod = 95
 )
 
+var zipMethods = map[ZipCompressionMethod]string{
+	Store:   "store",
+	Deflate: "deflate",
+	BZIP2:   "bzip2",
+	LZMA:    "lzma",
+	ZSTD:    "zstd",
+	XZ:      "xz",
+}
+
+func (m ZipCompressionMethod) String() string {
+	if s, ok := zipMethods[m]; ok {
+		return s
+	}
+	return fmt.Sprintf("method %d", uint16(m))
+}
+
 type Zip struct {
 	CompressionLevel    int
 	MkdirAll            bool
@@ -53,24 +65,21 @@ type Zip struct {
 	the function will not return any error.
 */
 func (*Zip) CheckFormat(filename string) error {
-	l := atomic.LoadUint32(&readLimit)
-
-	// Get the header of filename
-	h, err := GetFileHeader(filename, l)
+	ok, err := detectFile(filename, isZip)
 	if err != nil {
 		return fmt.Errorf("problem looking at %s", filename)
 	}
-	mu.Lock()
-	defer mu.Unlock()
-
-	// Check if the file is a Zip file
-	var m = newMime("Zip", magic.Zip)
-	if !m.detector(h, l) {
+	if !ok {
 		return fmt.Errorf("%s is not a Zip file", filename)
 	}
 	return nil
 }
 
+// isZip reports whether s is the start of a zip archive.
+func isZip(s *Sniff) bool {
+	return magic.Zip(s.Head, s.limit)
+}
+
 func regDecomp(zr *zip.Reader) {
 	zr.RegisterDecompressor(uint16(ZSTD), func(r io.Reader) io.ReadCloser {
 		zr, err := zstd.NewReader(r)
@@ -98,68 +107,79 @@ func regDecomp(zr *zip.Reader) {
 /*
 	Extract will extract the file sent to the function
 */
-func (z *Zip) Extract(filename, destination string, p *mpb.Progress, start time.Time) (err error) {
-	b := AddNewBar(p, filename, start)
-	destination, err = z.topLevelDir(filename, destination)
-	if err != nil {
-		b.Abort(true)
-		return
-	}
-
-	for {
-		err = z.unzipNextFile(destination)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			b.Abort(true)
-			return fmt.Errorf("error reading file in zip archive: %v", err)
-		}
-	}
-	b.SetTotal(1, true)
-	return nil
+func (z *Zip) Extract(filename, destination string, p ProgressReporter) (err error) {
+	return extractArchive(z, filename, destination, p)
 }
 
-/*
-	topLevelDir will evaluate contents of the Rar file and checks for
-	a common root directory. If the root directory is found, the
-	destination will be modified to be relative to the root directory.
-*/
-func (z *Zip) topLevelDir(filename, destination string) (string, error) {
+func (z *Zip) extract(j *job, filename, destination string) (err error) {
 	f, err := os.Open(filename)
 	if err != nil {
-		return "", fmt.Errorf("error opening %s: %v", filename, err)
+		return fmt.Errorf("error opening %s: %v", filename, err)
 	}
 	defer f.Close()
 
 	fInfo, err := f.Stat()
 	if err != nil {
-		return "", fmt.Errorf("error getting file info for %s: %v", filename, err)
+		return fmt.Errorf("error getting file info for %s: %v", filename, err)
 	}
 
-	err = z.Open(f, fInfo.Size())
+	err = z.Open(j.source(f), fInfo.Size())
 	if err != nil {
-		return "", fmt.Errorf("error opening archive for reading: %v", err)
+		return fmt.Errorf("error opening archive for reading: %v", err)
 	}
 	defer z.Close()
 
-	var files = make([]string, len(z.zr.File))
-	for i := range z.zr.File {
-		files[i] = z.zr.File[i].Name
+	destination, err = z.topLevelDir(j, destination)
+	if err != nil {
+		return err
 	}
+	j.res.Destination = destination
 
-	if TopLevels(files) {
-		destination = filepath.Join(destination, DirFromFile(filename))
+	return z.extractAll(j, destination)
+}
+
+/*
+	extractAll will extract every remaining file in the open archive
+*/
+func (z *Zip) extractAll(j *job, destination string) error {
+	err := j.extractFrom(func() error {
+		return z.unzipNextFile(j, destination)
+	})
+	if err != nil {
+		return fmt.Errorf("error reading file in zip archive: %w", err)
 	}
+	return nil
+}
 
-	return destination, nil
+/*
+	topLevelDir returns the directory within destination that the open
+	archive is extracted into. The top level entries are listed from the
+	central directory, so no entry is read to find them. The destination
+	of a deferred archive is left as it is, to be chosen once extracted.
+*/
+func (z *Zip) topLevelDir(j *job, destination string) (string, error) {
+	if j.pending {
+		return destination, nil
+	}
+	if j.needsTops() {
+		for _, zf := range z.zr.File {
+			f := File{FileInfo: zf.FileInfo(), Header: zf.FileHeader}
+			if !j.selected(zf.Name, f) {
+				continue
+			}
+			if name, ok := j.remap(zf.Name); ok {
+				j.addTop(name)
+			}
+		}
+	}
+	return j.topLevel(destination)
 }
 
 /*
 	unzipNextFile will read the next file in the Rar archive, check the path
 	and move on to perform the extraction via unzipFile
 */
-func (z *Zip) unzipNextFile(destination string) (err error) {
+func (z *Zip) unzipNextFile(j *job, destination string) (err error) {
 	f, err := z.Read()
 	if err != nil {
 		return
@@ -170,23 +190,29 @@ func (z *Zip) unzipNextFile(destination string) (err error) {
 	if !ok {
 		return fmt.Errorf("expected header to be *zip.FileHeader but found %T", f.Header)
 	}
+	if !j.selected(fh.Name, f) {
+		return nil
+	}
+	name, ok := j.remap(fh.Name)
+	if !ok {
+		return nil
+	}
 
-	err = CheckPath(destination, fh.Name)
+	dest, err := j.resolve(destination, name)
 	if err != nil {
-		return fmt.Errorf("checking path: %v", err)
+		return
 	}
 
-	return z.unzipFile(f, destination, &fh)
+	return z.unzipFile(j, f, dest, &fh)
 }
 
 /*
-	unzipFile will extract the file sent to the function
+	unzipFile will extract the file sent to the function to destination,
+	the path already resolved within the destination of the archive
 */
-func (z *Zip) unzipFile(f File, destination string, fh *zip.FileHeader) (err error) {
-	destination = filepath.Join(destination, fh.Name)
-
+func (z *Zip) unzipFile(j *job, f File, destination string, fh *zip.FileHeader) (err error) {
 	if f.IsDir() {
-		return Mkdir(destination, fh.Mode())
+		return j.mkdir(destination, f)
 	}
 
 	if IsSymlink(fh.FileInfo()) {
@@ -195,10 +221,10 @@ func (z *Zip) unzipFile(f File, destination string, fh *zip.FileHeader) (err err
 		if err != nil {
 			return fmt.Errorf("%s: error reading symlink target: %v", fh.Name, err)
 		}
-		return WriteSymlink(destination, strings.TrimSpace(buf.String()))
+		return j.symlink(destination, strings.TrimSpace(buf.String()), f)
 	}
 
-	return WriteFile(destination, f, fh.Mode())
+	return j.writeFile(destination, f)
 }
 
 /*
@@ -255,11 +281,16 @@ func (z *Zip) Read() (f File, err error) {
 	Close will close the Zip archive
 */
 func (z *Zip) Close() {
-	if z.zr == nil {
+	if z.zr != nil {
 		z.zr = nil
 	}
 }
 
+func init() {
+	RegisterFormat("zip", isZip, func() Extractor { return NewZip() }, 0)
+	RegisterExtensions("zip", ".zip")
+}
+
 func NewZip() *Zip {
 	return &Zip{
 		CompressionLevel:    flate.DefaultCompression,